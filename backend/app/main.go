@@ -3,6 +3,7 @@ package main
 import (
 	_ "backend/docs"
 	"backend/internal/config"
+	"backend/internal/fetcher"
 	"backend/internal/lib/logger/slogpretty"
 	"backend/internal/middleware/logger"
 	"backend/internal/repository"
@@ -41,7 +42,23 @@ func main() {
 	})
 
 	repos := repository.NewRepositories()
-	services := service.NewService(repos)
+	fetchers, err := fetcher.NewRegistry(&cfg, log)
+	if err != nil {
+		log.Error("Не удалось настроить загрузчик", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	rewriter, err := service.NewRewriter(cfg.Rewrite)
+	if err != nil {
+		log.Error("Не удалось загрузить правила переписывания ссылок", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	signer, err := service.NewSigner(cfg.Signing)
+	if err != nil {
+		log.Error("Не удалось загрузить ключ подписи архивов", slog.String("error", err.Error()))
+		os.Exit(1)
+	}
+	service.CleanupPartialDownloads(log)
+	services := service.NewService(repos, fetchers, rewriter, signer)
 	handlers := routes.NewHandler(services)
 
 	// Применяем CORS middleware
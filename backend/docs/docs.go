@@ -15,11 +15,41 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
+        "/.well-known/archiver-signing-key": {
+            "get": {
+                "description": "Открытый ключ ed25519 в PEM, которым проверяются подписи /api/archives/{id}/signature.\nПодпись ставится на 32 байта SHA-256 архива, а не на сам архив: проверяйте её по дайджесту",
+                "produces": [
+                    "application/x-pem-file"
+                ],
+                "tags": [
+                    "archives"
+                ],
+                "summary": "Открытый ключ подписи архивов",
+                "responses": {
+                    "200": {
+                        "description": "Открытый ключ в PEM",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Подпись архивов не настроена",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
         "/api/archives/{id}/download": {
             "get": {
-                "description": "Скачивает архив, связанный с задачей, по её ID",
+                "description": "Скачивает архив, связанный с задачей, по её ID. По умолчанию отдаётся архив в формате задачи,\nархив в другом формате собирается при первом запросе и кэшируется.\nВ режиме stream архив собирается на лету, Content-Length есть, если размер известен заранее (tar и zip без сжатия)",
                 "produces": [
-                    "application/zip"
+                    "application/zip",
+                    "application/x-tar",
+                    "application/gzip",
+                    "application/zstd",
+                    "application/octet-stream"
                 ],
                 "tags": [
                     "archives"
@@ -33,6 +63,18 @@ const docTemplate = `{
                         "name": "id",
                         "in": "path",
                         "required": true
+                    },
+                    {
+                        "enum": [
+                            "zip",
+                            "tar",
+                            "tar.gz",
+                            "tar.zst"
+                        ],
+                        "type": "string",
+                        "description": "Формат архива",
+                        "name": "format",
+                        "in": "query"
                     }
                 ],
                 "responses": {
@@ -43,7 +85,7 @@ const docTemplate = `{
                         }
                     },
                     "400": {
-                        "description": "Неверный ID задачи",
+                        "description": "Неверный ID задачи или формат архива, например zip для задачи с ключом получателя age",
                         "schema": {
                             "type": "string"
                         }
@@ -61,24 +103,63 @@ const docTemplate = `{
                         }
                     }
                 }
-            }
-        },
-        "/api/tasks/create": {
-            "post": {
-                "description": "Создает новую задачу и возвращает её ID",
+            },
+            "head": {
+                "description": "Скачивает архив, связанный с задачей, по её ID. По умолчанию отдаётся архив в формате задачи,\nархив в другом формате собирается при первом запросе и кэшируется.\nВ режиме stream архив собирается на лету, Content-Length есть, если размер известен заранее (tar и zip без сжатия)",
+                "produces": [
+                    "application/zip",
+                    "application/x-tar",
+                    "application/gzip",
+                    "application/zstd",
+                    "application/octet-stream"
+                ],
                 "tags": [
-                    "tasks"
+                    "archives"
+                ],
+                "summary": "Скачать архив по ID задачи",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "format": "int64",
+                        "description": "Task ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "zip",
+                            "tar",
+                            "tar.gz",
+                            "tar.zst"
+                        ],
+                        "type": "string",
+                        "description": "Формат архива",
+                        "name": "format",
+                        "in": "query"
+                    }
                 ],
-                "summary": "Создать новую задачу",
                 "responses": {
-                    "201": {
-                        "description": "Задача успешно создана с ID: {id}",
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID задачи или формат архива, например zip для задачи с ключом получателя age",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Архив не найден",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Ошибка при создании задачи",
+                        "description": "Ошибка открытия файла",
                         "schema": {
                             "type": "string"
                         }
@@ -86,24 +167,74 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/tasks/{id}/add-link": {
-            "post": {
-                "description": "Добавляет ссылку к задаче по её ID",
+        "/api/archives/{id}/signature": {
+            "get": {
+                "description": "Отдаёт подпись ed25519 (64 байта) архива. Подписывается не сам архив, а его SHA-256:\nсообщение для ed25519 - 32 байта дайджеста SHA-256 архива (то же значение, что в checksum без префикса sha256:).\nДля проверки посчитайте SHA-256 скачанного архива и проверьте подпись этого дайджеста открытым ключом\nиз /.well-known/archiver-signing-key, например openssl dgst -sha256 -binary archive.zip | openssl pkeyutl -verify -pubin -inkey key.pem -rawin -sigfile archive.zip.sig.\nАрхив в другом формате в режиме file собирается при первом запросе подписи, потоковые архивы подписываются только в формате задачи",
+                "produces": [
+                    "application/octet-stream"
+                ],
                 "tags": [
-                    "tasks"
+                    "archives"
                 ],
-                "summary": "Добавить ссылку к задаче",
+                "summary": "Скачать подпись архива",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "ID задачи",
+                        "format": "int64",
+                        "description": "Task ID",
                         "name": "id",
                         "in": "path",
                         "required": true
                     },
                     {
+                        "enum": [
+                            "zip",
+                            "tar",
+                            "tar.gz",
+                            "tar.zst"
+                        ],
                         "type": "string",
-                        "description": "Ссылка для добавления",
+                        "description": "Формат архива, по умолчанию формат задачи",
+                        "name": "format",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "file"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID задачи или формат архива",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Архив не найден, не подписан или подпись архивов не настроена",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/links/rewrite": {
+            "post": {
+                "description": "Показывает, во что превратится ссылка после применения правил из REWRITE_RULES_FILE, и какие заголовки добавит правило",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "links"
+                ],
+                "summary": "Проверить правила переписывания ссылки",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Ссылка для проверки",
                         "name": "link",
                         "in": "formData",
                         "required": true
@@ -111,19 +242,99 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Ссылка успешно добавлена к задаче",
+                        "description": "Результат применения правил",
+                        "schema": {
+                            "$ref": "#/definitions/backend_internal_service.RewriteResult"
+                        }
+                    },
+                    "400": {
+                        "description": "Пустая ссылка",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/links/validate": {
+            "post": {
+                "description": "Для каждой ссылки отправляет HEAD (или GET первого байта, если HEAD не поддерживается) и проверяет доступность,\nтип содержимого и размер. Если основная ссылка не прошла проверку, проверяются зеркала",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "links"
+                ],
+                "summary": "Проверить ссылки без добавления в задачу",
+                "parameters": [
+                    {
+                        "description": "Ссылки и параметры запроса по умолчанию",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_routes.ValidateLinksRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат по каждой ссылке",
+                        "schema": {
+                            "$ref": "#/definitions/internal_routes.ValidateLinksResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Некорректное тело запроса",
                         "schema": {
                             "type": "string"
                         }
+                    }
+                }
+            }
+        },
+        "/api/tasks": {
+            "post": {
+                "description": "Создаёт задачу и сразу добавляет в неё ссылки. Для каждой ссылки возвращается, принята она или отклонена.\nЗадача закрывается для новых ссылок: архив собирается, когда обработаны все принятые файлы",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Создать задачу со списком ссылок",
+                "parameters": [
+                    {
+                        "description": "Ссылки и параметры запроса по умолчанию",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_routes.CreateTaskRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Задача создана",
+                        "schema": {
+                            "$ref": "#/definitions/internal_routes.CreateTaskResponse"
+                        }
                     },
                     "400": {
-                        "description": "Неверный ID задачи или пустая ссылка",
+                        "description": "Некорректное тело запроса",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Ошибка при добавлении ссылки к задаче",
+                        "description": "Ошибка при создании задачи",
                         "schema": {
                             "type": "string"
                         }
@@ -131,68 +342,1053 @@ const docTemplate = `{
                 }
             }
         },
-        "/api/tasks/{id}/status": {
-            "get": {
-                "description": "Возвращает статусы задачи по её ID. В случае, когда ни один файл не удалось скачать, архив не будет возвращён.\nЕсли задача завершена успешно/удалось установить хоть один файл на момент завершения, возвращает ссылку на скачивание архива",
+        "/api/tasks/create": {
+            "post": {
+                "description": "Создает новую задачу и возвращает её ID",
                 "tags": [
                     "tasks"
                 ],
-                "summary": "Получить статусы задачи",
+                "summary": "Создать новую задачу",
                 "parameters": [
+                    {
+                        "enum": [
+                            "zip",
+                            "tar",
+                            "tar.gz",
+                            "tar.zst"
+                        ],
+                        "type": "string",
+                        "description": "Формат архива, по умолчанию ARCHIVE_FORMAT",
+                        "name": "format",
+                        "in": "formData"
+                    },
+                    {
+                        "enum": [
+                            "auto",
+                            "deflate",
+                            "store"
+                        ],
+                        "type": "string",
+                        "description": "Сжатие записей zip, по умолчанию ARCHIVE_COMPRESSION",
+                        "name": "compression",
+                        "in": "formData"
+                    },
                     {
                         "type": "integer",
-                        "description": "ID задачи",
-                        "name": "id",
-                        "in": "path",
-                        "required": true
+                        "description": "Уровень сжатия 1-9, по умолчанию ARCHIVE_COMPRESSION_LEVEL",
+                        "name": "compression_level",
+                        "in": "formData"
+                    },
+                    {
+                        "enum": [
+                            "file",
+                            "stream"
+                        ],
+                        "type": "string",
+                        "description": "file - архив собирается на диске, stream - на лету при скачивании, по умолчанию ARCHIVE_MODE",
+                        "name": "archive_mode",
+                        "in": "formData"
+                    },
+                    {
+                        "enum": [
+                            "flat",
+                            "host",
+                            "type"
+                        ],
+                        "type": "string",
+                        "description": "Раскладка файлов по папкам: flat - все в корне, host - по хосту ссылки, type - по типу содержимого, по умолчанию ARCHIVE_LAYOUT",
+                        "name": "layout",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пароль для шифрования архива: zip - AES-256 (WinZip AE-2), tar - age",
+                        "name": "passphrase",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Открытый ключ age (age1...) для шифрования tar-архива",
+                        "name": "recipient",
+                        "in": "formData"
                     }
                 ],
                 "responses": {
-                    "200": {
-                        "description": "Статусы задачи успешно получены",
+                    "201": {
+                        "description": "Задача успешно создана с ID: {id}",
                         "schema": {
-                            "$ref": "#/definitions/internal_routes.GetStatusesResponse"
+                            "type": "string"
                         }
                     },
                     "400": {
-                        "description": "Неверный ID задачи",
+                        "description": "Некорректные параметры архива",
                         "schema": {
                             "type": "string"
                         }
                     },
                     "500": {
-                        "description": "Ошибка при получении или сериализации статусов задачи",
+                        "description": "Ошибка при создании задачи",
                         "schema": {
                             "type": "string"
                         }
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "internal_routes.GetStatusesResponse": {
-            "type": "object",
-            "properties": {
-                "download_link": {
-                    "type": "string"
-                },
-                "task": {
-                    "$ref": "#/definitions/internal_routes.Task"
-                }
-            }
         },
-        "internal_routes.Task": {
-            "type": "object",
-            "properties": {
-                "errors": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
-                },
-                "status": {
-                    "type": "string"
+        "/api/tasks/{id}/add-link": {
+            "post": {
+                "description": "Добавляет ссылку к задаче по её ID",
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Добавить ссылку к задаче",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID задачи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ссылка для добавления",
+                        "name": "link",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Зеркало того же файла, пробуются по порядку, если основная ссылка недоступна",
+                        "name": "mirror",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Ссылка - шаблон: [001-120] - диапазон, {a,b,c} - перечисление, {date:2024-01-01..2024-01-31|20060102} - даты",
+                        "name": "expand",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Только показать, во что раскроется шаблон, не добавляя ссылки",
+                        "name": "dry_run",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Ожидаемая контрольная сумма файла: sha256:…, sha512:… или md5:…",
+                        "name": "checksum",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Папка файла в архиве, важнее раскладки архива",
+                        "name": "folder",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "Проверить доступность, тип и размер файла до добавления",
+                        "name": "preflight",
+                        "in": "formData"
+                    },
+                    {
+                        "enum": [
+                            "file",
+                            "extract",
+                            "feed"
+                        ],
+                        "type": "string",
+                        "description": "Режим ссылки: file (по умолчанию), extract - разобрать HTML-страницу, feed - добавить вложения RSS/Atom-ленты",
+                        "name": "mode",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "extract: CSS-селектор элементов со ссылками на файлы",
+                        "name": "selector",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "extract: регулярное выражение для отбора ссылок",
+                        "name": "pattern",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "extract: глубина обхода связанных страниц (0-3)",
+                        "name": "depth",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "boolean",
+                        "description": "extract: не выходить за пределы хоста страницы",
+                        "name": "same_host",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "feed: вложения, опубликованные не раньше даты (2006-01-02 или RFC 3339)",
+                        "name": "since",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "feed: вложения, опубликованные не позже даты (2006-01-02 или RFC 3339)",
+                        "name": "until",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Заголовок запроса в формате Name: value",
+                        "name": "header",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Кука в формате name=value",
+                        "name": "cookie",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Логин для Basic-авторизации",
+                        "name": "username",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пароль для Basic-авторизации",
+                        "name": "password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer-токен",
+                        "name": "bearer_token",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User-Agent запроса",
+                        "name": "user_agent",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Прокси для этой ссылки (http, https, socks5)",
+                        "name": "proxy",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат пробного раскрытия шаблона (dry_run)",
+                        "schema": {
+                            "$ref": "#/definitions/backend_internal_service.TemplatePreview"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID задачи или пустая ссылка",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "409": {
+                        "description": "Ссылка уже добавлена в задачу (DEDUP_MODE=reject)",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "422": {
+                        "description": "Ссылка не прошла предварительную проверку",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка при добавлении ссылки к задаче",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tasks/{id}/import": {
+            "post": {
+                "description": "Принимает файл со списком ссылок и добавляет каждую строку к задаче: текст (по ссылке в строке),\nCSV с колонками url, filename, checksum или Metalink 4 (.meta4) с зеркалами и хэшами. Для каждой строки возвращается, принята она или отклонена",
+                "consumes": [
+                    "multipart/form-data"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Импортировать список ссылок",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID задачи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "file",
+                        "description": "Файл со списком ссылок",
+                        "name": "file",
+                        "in": "formData",
+                        "required": true
+                    },
+                    {
+                        "enum": [
+                            "txt",
+                            "csv",
+                            "meta4"
+                        ],
+                        "type": "string",
+                        "description": "Формат файла, по умолчанию определяется по расширению",
+                        "name": "format",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Заголовок запроса в формате Name: value, для всех ссылок",
+                        "name": "header",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "array",
+                        "items": {
+                            "type": "string"
+                        },
+                        "collectionFormat": "multi",
+                        "description": "Кука в формате name=value, для всех ссылок",
+                        "name": "cookie",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Логин для Basic-авторизации",
+                        "name": "username",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Пароль для Basic-авторизации",
+                        "name": "password",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Bearer-токен",
+                        "name": "bearer_token",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "User-Agent запроса",
+                        "name": "user_agent",
+                        "in": "formData"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Прокси для всех ссылок (http, https, socks5)",
+                        "name": "proxy",
+                        "in": "formData"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат по каждой строке",
+                        "schema": {
+                            "$ref": "#/definitions/internal_routes.AppendLinksResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID задачи, отсутствует файл или файл не удалось разобрать",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tasks/{id}/links": {
+            "post": {
+                "description": "Добавляет ссылки к задаче по её ID. Для каждой ссылки возвращается, принята она или отклонена",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Добавить несколько ссылок к задаче",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID задачи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Ссылки и параметры запроса по умолчанию",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/internal_routes.AppendLinksRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Результат по каждой ссылке",
+                        "schema": {
+                            "$ref": "#/definitions/internal_routes.AppendLinksResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID задачи или некорректное тело запроса",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "404": {
+                        "description": "Задача не найдена",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        },
+        "/api/tasks/{id}/status": {
+            "get": {
+                "description": "Возвращает статусы задачи по её ID. Запрос ничего не меняет: архив собирается в фоне, когда обработаны все файлы,\nна это время задача получает статус \"Архивируется\". В случае, когда ни один файл не удалось скачать, архив не будет возвращён.\nЕсли задача завершена успешно/удалось установить хоть один файл на момент завершения, возвращает ссылку на скачивание архива",
+                "tags": [
+                    "tasks"
+                ],
+                "summary": "Получить статусы задачи",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "ID задачи",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "Статусы задачи успешно получены",
+                        "schema": {
+                            "$ref": "#/definitions/internal_routes.GetStatusesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Неверный ID задачи",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "500": {
+                        "description": "Ошибка при получении или сериализации статусов задачи",
+                        "schema": {
+                            "type": "string"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "backend_internal_repository.ArchiveEntry": {
+            "type": "object",
+            "properties": {
+                "compressed_size": {
+                    "type": "integer"
+                },
+                "method": {
+                    "type": "string",
+                    "enum": [
+                        "store",
+                        "deflate"
+                    ]
+                },
+                "name": {
+                    "type": "string"
+                },
+                "ratio": {
+                    "type": "number"
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "backend_internal_repository.ArchiveInfo": {
+            "type": "object",
+            "properties": {
+                "checksum": {
+                    "type": "string"
+                },
+                "entries": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/backend_internal_repository.ArchiveEntry"
+                    }
+                },
+                "format": {
+                    "type": "string"
+                },
+                "original_size": {
+                    "type": "integer"
+                },
+                "ratio": {
+                    "type": "number"
+                },
+                "signature": {
+                    "type": "array",
+                    "items": {
+                        "type": "integer"
+                    }
+                },
+                "size": {
+                    "type": "integer"
+                }
+            }
+        },
+        "backend_internal_repository.ArchiveOptions": {
+            "type": "object",
+            "properties": {
+                "compression": {
+                    "description": "Compression - сжатие записей zip: auto - по пробе содержимого, deflate - всё, кроме уже сжатых типов, store - без сжатия",
+                    "type": "string",
+                    "enum": [
+                        "auto",
+                        "deflate",
+                        "store"
+                    ]
+                },
+                "compression_level": {
+                    "description": "CompressionLevel - уровень сжатия 1-9 для deflate, gzip и zstd",
+                    "type": "integer"
+                },
+                "encrypted": {
+                    "type": "boolean"
+                },
+                "format": {
+                    "description": "Format - формат архива: zip, tar, tar.gz или tar.zst",
+                    "type": "string",
+                    "enum": [
+                        "zip",
+                        "tar",
+                        "tar.gz",
+                        "tar.zst"
+                    ]
+                },
+                "layout": {
+                    "description": "Layout - раскладка файлов по папкам: flat - все в корне, host - по хосту ссылки, type - по типу содержимого",
+                    "type": "string",
+                    "enum": [
+                        "flat",
+                        "host",
+                        "type"
+                    ]
+                },
+                "mode": {
+                    "description": "Mode - file: архив собирается на диске после загрузки файлов, stream: собирается при каждом скачивании",
+                    "type": "string",
+                    "enum": [
+                        "file",
+                        "stream"
+                    ]
+                },
+                "passphrase": {
+                    "description": "Passphrase - пароль для шифрования архива, приходит только в запросе: в задаче хранится\nSealedPassphrase, зашифрованный ключом, который есть только в памяти сервиса",
+                    "type": "string"
+                },
+                "recipient": {
+                    "description": "Recipient - открытый ключ age (age1...), которым шифруются tar-архивы",
+                    "type": "string"
+                }
+            }
+        },
+        "backend_internal_repository.File": {
+            "type": "object",
+            "properties": {
+                "actual_checksum": {
+                    "type": "string"
+                },
+                "aliases": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "checksum": {
+                    "type": "string"
+                },
+                "content_type": {
+                    "description": "ContentType, Size и LastModified - тип, размер и время изменения файла по данным сервера",
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "expected_checksum": {
+                    "description": "ExpectedChecksum - контрольная сумма, переданная клиентом, в виде algo:hex.\nChecksum - SHA-256 скачанного файла, считается всегда. ActualChecksum - сумма скачанного\nфайла в алгоритме ExpectedChecksum, заполняется, только если клиент передал сумму",
+                    "type": "string"
+                },
+                "final_url": {
+                    "description": "FinalURL - адрес, с которого файл фактически скачан, Redirects - все промежуточные переходы",
+                    "type": "string"
+                },
+                "folder": {
+                    "type": "string"
+                },
+                "last_modified": {
+                    "type": "string"
+                },
+                "link": {
+                    "type": "string"
+                },
+                "mirrors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "name": {
+                    "description": "Name - имя файла внутри архива, Folder - папка, заданная клиентом: она важнее раскладки архива",
+                    "type": "string"
+                },
+                "original_link": {
+                    "description": "OriginalLink - ссылка до применения правила переписывания RewriteRule",
+                    "type": "string"
+                },
+                "redirects": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "rewrite_rule": {
+                    "type": "string"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "source": {
+                    "description": "Source - ссылка (основная или одно из зеркал), с которой файл удалось скачать",
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "backend_internal_repository.RequestOptions": {
+            "type": "object",
+            "properties": {
+                "bearer_token": {
+                    "type": "string"
+                },
+                "cookies": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "headers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "password": {
+                    "type": "string"
+                },
+                "proxy": {
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "backend_internal_service.LinkResult": {
+            "type": "object",
+            "properties": {
+                "accepted": {
+                    "type": "boolean"
+                },
+                "content_type": {
+                    "description": "ContentType, Size и Warnings - результат предварительной проверки, если она выполнялась",
+                    "type": "string"
+                },
+                "duplicate_of": {
+                    "description": "DuplicateOf - номер файла задачи, с которым объединена повторная ссылка",
+                    "type": "integer"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "link": {
+                    "type": "string"
+                },
+                "merged": {
+                    "description": "Merged - ссылка оказалась повтором и объединена с файлом DuplicateOf, новый файл не создан",
+                    "type": "boolean"
+                },
+                "row": {
+                    "description": "Row - номер строки (или файла в Metalink) при импорте списка",
+                    "type": "integer"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "warnings": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "backend_internal_service.PreflightResult": {
+            "type": "object",
+            "properties": {
+                "content_type": {
+                    "type": "string"
+                },
+                "error": {
+                    "type": "string"
+                },
+                "final_url": {
+                    "type": "string"
+                },
+                "link": {
+                    "type": "string"
+                },
+                "ok": {
+                    "type": "boolean"
+                },
+                "size": {
+                    "type": "integer"
+                },
+                "source": {
+                    "type": "string"
+                },
+                "warnings": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                }
+            }
+        },
+        "backend_internal_service.RewriteResult": {
+            "type": "object",
+            "properties": {
+                "headers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "original": {
+                    "type": "string"
+                },
+                "rewritten": {
+                    "type": "string"
+                },
+                "rule": {
+                    "type": "string"
+                }
+            }
+        },
+        "backend_internal_service.TemplatePreview": {
+            "type": "object",
+            "properties": {
+                "links": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "remaining": {
+                    "type": "integer"
+                },
+                "total": {
+                    "type": "integer"
+                }
+            }
+        },
+        "internal_routes.AppendLinksRequest": {
+            "type": "object",
+            "properties": {
+                "links": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_routes.LinkRequest"
+                    }
+                },
+                "options": {
+                    "$ref": "#/definitions/backend_internal_repository.RequestOptions"
+                }
+            }
+        },
+        "internal_routes.AppendLinksResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/backend_internal_service.LinkResult"
+                    }
+                }
+            }
+        },
+        "internal_routes.CreateTaskRequest": {
+            "type": "object",
+            "properties": {
+                "archive": {
+                    "description": "Archive - параметры архива задачи",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/backend_internal_repository.ArchiveOptions"
+                        }
+                    ]
+                },
+                "links": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_routes.LinkRequest"
+                    }
+                },
+                "options": {
+                    "description": "Options - параметры запроса по умолчанию для всех ссылок",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/backend_internal_repository.RequestOptions"
+                        }
+                    ]
+                }
+            }
+        },
+        "internal_routes.CreateTaskResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "integer"
+                },
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/backend_internal_service.LinkResult"
+                    }
+                }
+            }
+        },
+        "internal_routes.GetStatusesResponse": {
+            "type": "object",
+            "properties": {
+                "download_link": {
+                    "type": "string"
+                },
+                "task": {
+                    "$ref": "#/definitions/internal_routes.Task"
+                }
+            }
+        },
+        "internal_routes.LinkRequest": {
+            "type": "object",
+            "properties": {
+                "bearer_token": {
+                    "type": "string"
+                },
+                "checksum": {
+                    "description": "Checksum - ожидаемая контрольная сумма: sha256:…, sha512:… или md5:…",
+                    "type": "string"
+                },
+                "cookies": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "depth": {
+                    "type": "integer"
+                },
+                "expand": {
+                    "description": "Expand - url является шаблоном с диапазонами, перечислениями или датами",
+                    "type": "boolean"
+                },
+                "folder": {
+                    "description": "Folder - папка файла в архиве, важнее раскладки архива",
+                    "type": "string"
+                },
+                "headers": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "mirrors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "mode": {
+                    "type": "string",
+                    "enum": [
+                        "file",
+                        "extract",
+                        "feed"
+                    ]
+                },
+                "name": {
+                    "type": "string"
+                },
+                "password": {
+                    "type": "string"
+                },
+                "pattern": {
+                    "type": "string"
+                },
+                "preflight": {
+                    "description": "Preflight - проверить доступность, тип и размер файла до добавления",
+                    "type": "boolean"
+                },
+                "proxy": {
+                    "type": "string"
+                },
+                "same_host": {
+                    "type": "boolean"
+                },
+                "selector": {
+                    "type": "string"
+                },
+                "since": {
+                    "type": "string"
+                },
+                "until": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                },
+                "user_agent": {
+                    "type": "string"
+                },
+                "username": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_routes.Task": {
+            "type": "object",
+            "properties": {
+                "archive": {
+                    "description": "Archive - параметры архива, заданные при создании задачи",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/backend_internal_repository.ArchiveOptions"
+                        }
+                    ]
+                },
+                "archive_info": {
+                    "description": "ArchiveInfo - сведения об основном архиве, появляются, когда архив можно скачать",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/backend_internal_repository.ArchiveInfo"
+                        }
+                    ]
+                },
+                "archives": {
+                    "description": "Archives - архивы в других форматах, собранные по запросу, по формату",
+                    "type": "object",
+                    "additionalProperties": {
+                        "$ref": "#/definitions/backend_internal_repository.ArchiveInfo"
+                    }
+                },
+                "created_at": {
+                    "description": "CreatedAt - время создания задачи, FinishedAt - время, когда обработаны все файлы",
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "files": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/backend_internal_repository.File"
+                    }
+                },
+                "finished_at": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "internal_routes.ValidateLinksRequest": {
+            "type": "object",
+            "properties": {
+                "links": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_routes.LinkRequest"
+                    }
+                },
+                "options": {
+                    "$ref": "#/definitions/backend_internal_repository.RequestOptions"
+                }
+            }
+        },
+        "internal_routes.ValidateLinksResponse": {
+            "type": "object",
+            "properties": {
+                "results": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/backend_internal_service.PreflightResult"
+                    }
                 }
             }
         }
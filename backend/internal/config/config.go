@@ -8,9 +8,18 @@ import (
 )
 
 type Config struct {
-	HTTPServer        HTTPServer
+	HTTPServer HTTPServer
+	Fetchers   Fetchers
+	Downloads  Downloads
+	Rewrite    Rewrite
+	Preflight  Preflight
+	Archives   Archives
+	Signing    Signing
+	// Что делать с повторной ссылкой в задаче: reject - отклонить, merge - считать тем же файлом, off - добавить как новый
+	DedupMode         string `env:"DEDUP_MODE" env-default:"reject"`
 	Environment       string `env:"ENVIRONMENT" env-default:"development"`
 	AllowedExtensions string `env:"ALLOWED_EXTENSIONS" env-default:".pdf,.jpeg,.jpg"`
+	MaxFilesPerTask   int    `env:"MAX_FILES_PER_TASK" env-default:"3"`
 }
 
 type HTTPServer struct {
@@ -19,6 +28,91 @@ type HTTPServer struct {
 	IdleTimeout time.Duration `env:"HTTP_IDLE_TIMEOUT" env-default:"180s"`
 }
 
+type Rewrite struct {
+	// JSON-файл со списком правил [{"name", "match", "replace", "headers"}], применяются до проверки ссылки
+	RulesFile string `env:"REWRITE_RULES_FILE"`
+}
+
+// Preflight - предварительная проверка ссылки при добавлении: доступность, тип содержимого и размер
+type Preflight struct {
+	// Проверять все ссылки, даже если клиент не запросил проверку
+	Enabled bool          `env:"PREFLIGHT_ENABLED" env-default:"false"`
+	Timeout time.Duration `env:"PREFLIGHT_TIMEOUT" env-default:"10s"`
+	// Максимальный размер файла в байтах, 0 - без ограничения
+	MaxSize int64 `env:"PREFLIGHT_MAX_SIZE" env-default:"0"`
+}
+
+type Archives struct {
+	// Формат архива по умолчанию: zip, tar, tar.gz или tar.zst
+	Format string `env:"ARCHIVE_FORMAT" env-default:"zip"`
+	// file - архив собирается на диске, stream - собирается на лету при каждом скачивании и не занимает место
+	Mode string `env:"ARCHIVE_MODE" env-default:"file"`
+	// Сжатие записей zip: auto - по пробе содержимого, deflate - всё, кроме уже сжатых типов, store - без сжатия
+	Compression string `env:"ARCHIVE_COMPRESSION" env-default:"auto"`
+	// Уровень сжатия 1-9 для deflate, gzip и zstd
+	CompressionLevel int `env:"ARCHIVE_COMPRESSION_LEVEL" env-default:"6"`
+	// Раскладка файлов по папкам: flat - все в корне, host - по хосту ссылки, type - по типу содержимого
+	Layout string `env:"ARCHIVE_LAYOUT" env-default:"flat"`
+	// Уже сжатые MIME-типы, которые кладутся в zip без сжатия. Допускаются шаблоны вида image/*
+	StoreTypes []string `env:"ARCHIVE_STORE_TYPES" env-separator:"," env-default:"image/jpeg,image/png,image/gif,image/webp,image/avif,image/heic,video/*,audio/*,application/pdf,application/zip,application/gzip,application/zstd,application/x-7z-compressed,application/vnd.rar,application/x-rar-compressed,application/x-bzip2,application/x-xz"`
+}
+
+type Signing struct {
+	// PEM-файл с закрытым ключом ed25519 (PKCS#8), которым подписываются архивы. Без него архивы не подписываются
+	KeyFile string `env:"SIGNING_KEY_FILE"`
+}
+
+type Downloads struct {
+	// Количество повторов для каждой ссылки (основной и зеркал), задержка удваивается после каждой попытки
+	Retries    int           `env:"DOWNLOAD_RETRIES" env-default:"2"`
+	RetryDelay time.Duration `env:"DOWNLOAD_RETRY_DELAY" env-default:"1s"`
+}
+
+type Fetchers struct {
+	// Директории, из которых разрешено читать ссылки file://. Пустой список отключает схему.
+	FileRoots  []string      `env:"FILE_ROOTS" env-separator:","`
+	FTPTimeout time.Duration `env:"FTP_TIMEOUT" env-default:"30s"`
+	S3         S3
+	Proxy      Proxy
+	TLS        TLS
+	Redirects  Redirects
+}
+
+type Redirects struct {
+	MaxHops         int  `env:"REDIRECT_MAX_HOPS" env-default:"10"`
+	ForbidDowngrade bool `env:"REDIRECT_FORBID_DOWNGRADE" env-default:"true"`
+	// Разрешать переходы только в пределах зарегистрированного домена исходной ссылки
+	SameDomain bool `env:"REDIRECT_SAME_DOMAIN" env-default:"false"`
+	// Хосты (допускаются шаблоны вида *.example.com), на которые редирект разрешён всегда
+	AllowedHosts []string `env:"REDIRECT_ALLOWED_HOSTS" env-separator:","`
+}
+
+type TLS struct {
+	// PEM-файлы с дополнительными доверенными CA, к системным добавляются
+	CAFiles []string `env:"TLS_CA_FILES" env-separator:","`
+	// Клиентские сертификаты в формате host_pattern=cert.pem:key.pem, например *.corp.local=client.crt:client.key
+	ClientCerts []string `env:"TLS_CLIENT_CERTS" env-separator:","`
+	// Хосты, для которых проверка сертификата отключается. Только для тестовых окружений!
+	InsecureSkipVerifyHosts []string `env:"TLS_INSECURE_SKIP_VERIFY_HOSTS" env-separator:","`
+}
+
+// Proxy - исходящий прокси для загрузок. PROXY_SOCKS5 используется для схем,
+// для которых не задан отдельный HTTP(S)-прокси.
+type Proxy struct {
+	HTTP    string `env:"PROXY_HTTP"`
+	HTTPS   string `env:"PROXY_HTTPS"`
+	SOCKS5  string `env:"PROXY_SOCKS5"`
+	NoProxy string `env:"PROXY_NO_PROXY"`
+}
+
+type S3 struct {
+	Endpoint  string `env:"S3_ENDPOINT" env-default:"https://s3.amazonaws.com"`
+	Region    string `env:"S3_REGION" env-default:"us-east-1"`
+	AccessKey string `env:"S3_ACCESS_KEY"`
+	SecretKey string `env:"S3_SECRET_KEY"`
+	PathStyle bool   `env:"S3_PATH_STYLE" env-default:"true"`
+}
+
 func MustLoad() Config {
 	var cfg Config
 	if err := cleanenv.ReadEnv(&cfg); err != nil {
@@ -0,0 +1,65 @@
+package fetcher
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// DataFetcher отдаёт содержимое ссылок data: (RFC 2397) без обращения к сети
+type DataFetcher struct{}
+
+func NewDataFetcher() *DataFetcher {
+	return &DataFetcher{}
+}
+
+func (f *DataFetcher) Fetch(_ context.Context, req *Request) (*Response, error) {
+	mediaType, data, err := parseDataURL(req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Response{
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		ContentType:   mediaType,
+	}, nil
+}
+
+func parseDataURL(u *url.URL) (string, []byte, error) {
+	raw := strings.TrimPrefix(u.String(), u.Scheme+":")
+	meta, payload, ok := strings.Cut(raw, ",")
+	if !ok {
+		return "", nil, fmt.Errorf("некорректная ссылка data: отсутствует разделитель ','")
+	}
+
+	isBase64 := false
+	if strings.HasSuffix(strings.ToLower(meta), ";base64") {
+		isBase64 = true
+		meta = meta[:len(meta)-len(";base64")]
+	}
+	if meta == "" {
+		meta = "text/plain;charset=US-ASCII"
+	}
+
+	decoded, err := url.PathUnescape(payload)
+	if err != nil {
+		return "", nil, fmt.Errorf("некорректная ссылка data: %w", err)
+	}
+	if !isBase64 {
+		return meta, []byte(decoded), nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(decoded)
+	if err != nil {
+		data, err = base64.RawStdEncoding.DecodeString(decoded)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("некорректная ссылка data: %w", err)
+	}
+	return meta, data, nil
+}
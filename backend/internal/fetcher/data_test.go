@@ -0,0 +1,46 @@
+package fetcher
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"testing"
+)
+
+func TestDataFetcher(t *testing.T) {
+	tests := []struct {
+		link      string
+		mediaType string
+		body      string
+	}{
+		{"data:,hello%20world", "text/plain;charset=US-ASCII", "hello world"},
+		{"data:text/plain;charset=utf-8,%D0%BF%D1%80%D0%B8%D0%B2%D0%B5%D1%82", "text/plain;charset=utf-8", "привет"},
+		{"data:application/pdf;base64,JVBERi0=", "application/pdf", "%PDF-"},
+		{"data:application/octet-stream;BASE64,AAEC", "application/octet-stream", "\x00\x01\x02"},
+		{"data:;base64,aGk", "text/plain;charset=US-ASCII", "hi"},
+	}
+	for _, tt := range tests {
+		u, err := url.Parse(tt.link)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.link, err)
+		}
+		resp, err := NewDataFetcher().Fetch(context.Background(), &Request{URL: u})
+		if err != nil {
+			t.Errorf("%s: %v", tt.link, err)
+			continue
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if string(body) != tt.body || resp.ContentType != tt.mediaType || resp.ContentLength != int64(len(tt.body)) {
+			t.Errorf("%s: получено %q (%s, %d байт), ожидалось %q (%s)", tt.link, body, resp.ContentType, resp.ContentLength, tt.body, tt.mediaType)
+		}
+	}
+}
+
+func TestDataFetcherInvalid(t *testing.T) {
+	for _, link := range []string{"data:text/plain", "data:;base64,!!!"} {
+		u, _ := url.Parse(link)
+		if _, err := NewDataFetcher().Fetch(context.Background(), &Request{URL: u}); err == nil {
+			t.Errorf("%s: ожидалась ошибка", link)
+		}
+	}
+}
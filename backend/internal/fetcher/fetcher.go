@@ -0,0 +1,120 @@
+package fetcher
+
+import (
+	"backend/internal/config"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Fetcher загружает содержимое ссылки определённой схемы
+type Fetcher interface {
+	Fetch(ctx context.Context, req *Request) (*Response, error)
+}
+
+type Request struct {
+	URL      *url.URL
+	Header   http.Header
+	Username string
+	Password string
+	// Proxy перекрывает прокси из конфига для этого запроса
+	Proxy *url.URL
+}
+
+type Response struct {
+	Body          io.ReadCloser
+	ContentLength int64
+	ContentType   string
+	LastModified  time.Time
+	// FinalURL и Redirects заполняются для HTTP-запросов, пароли в ссылках скрыты
+	FinalURL  string
+	Redirects []string
+	// Partial - сервер ответил 206 на запрос с Range, ContentRange - заголовок Content-Range ответа
+	Partial      bool
+	ContentRange string
+	// ContentDisposition - заголовок Content-Disposition ответа, в нём сервер может передать имя файла
+	ContentDisposition string
+}
+
+type Registry struct {
+	fetchers map[string]Fetcher
+}
+
+func NewRegistry(cfg *config.Config, log *slog.Logger) (*Registry, error) {
+	transport, err := newTLSRouter(newTransport(cfg.Fetchers.Proxy), cfg.Fetchers.TLS, log)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Transport:     transport,
+		CheckRedirect: redirectPolicy{cfg: cfg.Fetchers.Redirects}.check,
+	}
+
+	r := &Registry{fetchers: make(map[string]Fetcher)}
+	r.Register("http", NewHTTPFetcher(client))
+	r.Register("https", NewHTTPFetcher(client))
+	r.Register("data", NewDataFetcher())
+	r.Register("ftp", NewFTPFetcher(cfg.Fetchers.FTPTimeout, cfg.Fetchers.Proxy))
+	r.Register("s3", NewS3Fetcher(client, cfg.Fetchers.S3))
+	if len(cfg.Fetchers.FileRoots) > 0 {
+		r.Register("file", NewFileFetcher(cfg.Fetchers.FileRoots))
+	}
+
+	return r, nil
+}
+
+func (r *Registry) Register(scheme string, f Fetcher) {
+	r.fetchers[strings.ToLower(scheme)] = f
+}
+
+func (r *Registry) Supports(scheme string) bool {
+	_, ok := r.fetchers[strings.ToLower(scheme)]
+	return ok
+}
+
+func (r *Registry) Fetch(ctx context.Context, req *Request) (*Response, error) {
+	f, ok := r.fetchers[strings.ToLower(req.URL.Scheme)]
+	if !ok {
+		return nil, fmt.Errorf("неподдерживаемая схема ссылки: %s", req.URL.Scheme)
+	}
+	return f.Fetch(ctx, req)
+}
+
+var preferredExtensions = map[string]string{
+	"application/pdf": ".pdf",
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"text/plain":      ".txt",
+}
+
+// FileName возвращает имя файла, под которым будет сохранено содержимое ссылки
+func FileName(u *url.URL) string {
+	if strings.EqualFold(u.Scheme, "data") {
+		mediaType, _, _ := parseDataURL(u)
+		return "data" + ExtensionByType(mediaType)
+	}
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return ""
+	}
+	return name
+}
+
+// ExtensionByType возвращает расширение файла для MIME-типа или пустую строку
+func ExtensionByType(mediaType string) string {
+	mediaType, _, _ = mime.ParseMediaType(mediaType)
+	if ext, ok := preferredExtensions[mediaType]; ok {
+		return ext
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileFetcher читает локальные файлы только из заданных корневых директорий
+type FileFetcher struct {
+	roots []string
+}
+
+func NewFileFetcher(roots []string) *FileFetcher {
+	f := &FileFetcher{}
+	for _, root := range roots {
+		root = strings.TrimSpace(root)
+		if root == "" {
+			continue
+		}
+		if abs, err := filepath.Abs(root); err == nil {
+			f.roots = append(f.roots, abs)
+		}
+	}
+	return f
+}
+
+func (f *FileFetcher) Fetch(_ context.Context, req *Request) (*Response, error) {
+	if host := req.URL.Host; host != "" && host != "localhost" {
+		return nil, fmt.Errorf("ссылки file:// поддерживаются только для локального хоста")
+	}
+
+	p := filepath.Clean(filepath.FromSlash(req.URL.Path))
+	for _, root := range f.roots {
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+
+		// os.Root не даёт выйти за пределы корня, в том числе по символическим ссылкам
+		r, err := os.OpenRoot(root)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть директорию %s: %w", root, err)
+		}
+		file, err := r.Open(rel)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("не удалось открыть файл: %w", err)
+		}
+
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("не удалось получить информацию о файле: %w", err)
+		}
+		if info.IsDir() {
+			file.Close()
+			return nil, fmt.Errorf("%s является директорией", p)
+		}
+
+		return &Response{
+			Body:          file,
+			ContentLength: info.Size(),
+			LastModified:  info.ModTime(),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("путь %s находится вне разрешённых директорий", p)
+}
@@ -0,0 +1,63 @@
+package fetcher
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileFetcherRoots(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "inside.txt"), []byte("inside"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// символическая ссылка внутри корня, ведущая наружу
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	f := NewFileFetcher([]string{root})
+
+	fetch := func(p string) (string, error) {
+		u := &url.URL{Scheme: "file", Path: filepath.ToSlash(p)}
+		resp, err := f.Fetch(context.Background(), &Request{URL: u})
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		return string(body), err
+	}
+
+	if body, err := fetch(filepath.Join(root, "inside.txt")); err != nil || body != "inside" {
+		t.Errorf("файл внутри корня: %q, %v", body, err)
+	}
+	for _, p := range []string{
+		filepath.Join(dir, "secret.txt"),
+		root + "/../secret.txt",
+		filepath.Join(root, "link.txt"),
+		root,
+	} {
+		if body, err := fetch(p); err == nil {
+			t.Errorf("%s: путь вне корня не отклонён, прочитано %q", p, body)
+		}
+	}
+}
+
+func TestFileFetcherWithoutRoots(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	os.WriteFile(path, []byte("x"), 0o644)
+	u := &url.URL{Scheme: "file", Path: filepath.ToSlash(path)}
+	if _, err := NewFileFetcher(nil).Fetch(context.Background(), &Request{URL: u}); err == nil {
+		t.Error("без FILE_ROOTS файл не должен читаться")
+	}
+}
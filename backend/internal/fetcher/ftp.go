@@ -0,0 +1,241 @@
+package fetcher
+
+import (
+	"backend/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// ErrFTPControlChars - в логине, пароле или пути FTP-ссылки есть CR, LF или NUL. Команды FTP
+// разделяются переводом строки, и такая ссылка отправила бы серверу посторонние команды
+var ErrFTPControlChars = errors.New("недопустимые управляющие символы в FTP-ссылке")
+
+// ErrFTPProxy - для ссылки задан HTTP(S)-прокси. FTP через него не ходит, а соединение
+// в обход прокси недопустимо
+var ErrFTPProxy = errors.New("ftp-ссылки можно скачивать только через SOCKS5-прокси")
+
+// FTPFetcher скачивает файлы по ftp:// в пассивном режиме. Управляющее соединение и
+// канал данных идут через PROXY_SOCKS5 или прокси из параметров ссылки
+type FTPFetcher struct {
+	timeout time.Duration
+	proxy   func(*url.URL) (*url.URL, error)
+}
+
+func NewFTPFetcher(timeout time.Duration, cfg config.Proxy) *FTPFetcher {
+	// у FTP нет отдельного прокси: берётся SOCKS5, а если задан только HTTP(S)-прокси,
+	// он тоже возвращается, чтобы ссылка была отклонена, а не ушла напрямую
+	proxyCfg := httpproxy.Config{HTTPProxy: cfg.SOCKS5, NoProxy: cfg.NoProxy}
+	if proxyCfg.HTTPProxy == "" {
+		proxyCfg.HTTPProxy = cfg.HTTPS
+	}
+	if proxyCfg.HTTPProxy == "" {
+		proxyCfg.HTTPProxy = cfg.HTTP
+	}
+	proxyFunc := proxyCfg.ProxyFunc()
+	return &FTPFetcher{
+		timeout: timeout,
+		proxy: func(u *url.URL) (*url.URL, error) {
+			return proxyFunc(&url.URL{Scheme: "http", Host: u.Host})
+		},
+	}
+}
+
+// dialer возвращает функцию подключения: напрямую или через SOCKS5-прокси
+func (f *FTPFetcher) dialer(req *Request) (func(ctx context.Context, network, addr string) (net.Conn, error), error) {
+	direct := &net.Dialer{Timeout: f.timeout}
+	proxyURL := req.Proxy
+	if proxyURL == nil {
+		var err error
+		if proxyURL, err = f.proxy(req.URL); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrProxyConnect, err)
+		}
+	}
+	if proxyURL == nil {
+		return direct.DialContext, nil
+	}
+	switch strings.ToLower(proxyURL.Scheme) {
+	case "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("%w, задан прокси %s", ErrFTPProxy, proxyURL.Redacted())
+	}
+	d, err := proxy.FromURL(proxyURL, direct)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrProxyConnect, err)
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := d.(proxy.ContextDialer).DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, classifyProxyError(err)
+		}
+		return conn, nil
+	}, nil
+}
+
+func (f *FTPFetcher) Fetch(ctx context.Context, req *Request) (*Response, error) {
+	user, pass := ftpCredentials(req)
+	if hasControlChars(user) || hasControlChars(pass) || hasControlChars(req.URL.Path) {
+		return nil, ErrFTPControlChars
+	}
+
+	addr := req.URL.Host
+	if req.URL.Port() == "" {
+		addr = net.JoinHostPort(req.URL.Hostname(), "21")
+	}
+
+	dial, err := f.dialer(req)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := dial(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось подключиться к FTP-серверу: %w", err)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+
+	c := &ftpConn{Conn: textproto.NewConn(conn), dial: dial, host: req.URL.Hostname()}
+	body, size, err := c.retrieve(ctx, req)
+	if err != nil {
+		stop()
+		c.Close()
+		return nil, err
+	}
+
+	return &Response{
+		Body:          &ftpBody{ReadCloser: body, conn: c, stop: stop},
+		ContentLength: size,
+	}, nil
+}
+
+type ftpConn struct {
+	*textproto.Conn
+	dial func(ctx context.Context, network, addr string) (net.Conn, error)
+	host string
+}
+
+func (c *ftpConn) cmd(expectCode int, format string, args ...any) (int, string, error) {
+	if _, err := c.Cmd(format, args...); err != nil {
+		return 0, "", err
+	}
+	return c.ReadResponse(expectCode)
+}
+
+func (c *ftpConn) retrieve(ctx context.Context, req *Request) (io.ReadCloser, int64, error) {
+	if _, _, err := c.ReadResponse(220); err != nil {
+		return nil, 0, fmt.Errorf("FTP-сервер не готов: %w", err)
+	}
+
+	user, pass := ftpCredentials(req)
+	code, _, err := c.cmd(0, "USER %s", user)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ошибка авторизации на FTP-сервере: %w", err)
+	}
+	if code == 331 {
+		if _, _, err := c.cmd(230, "PASS %s", pass); err != nil {
+			return nil, 0, fmt.Errorf("ошибка авторизации на FTP-сервере: %w", err)
+		}
+	} else if code != 230 {
+		return nil, 0, fmt.Errorf("ошибка авторизации на FTP-сервере, код: %d", code)
+	}
+
+	if _, _, err := c.cmd(200, "TYPE I"); err != nil {
+		return nil, 0, fmt.Errorf("не удалось переключить FTP в двоичный режим: %w", err)
+	}
+
+	filePath := req.URL.Path
+	size := int64(-1)
+	if _, msg, err := c.cmd(213, "SIZE %s", filePath); err == nil {
+		if n, err := strconv.ParseInt(strings.TrimSpace(msg), 10, 64); err == nil {
+			size = n
+		}
+	}
+
+	dataAddr, err := c.passive()
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := c.dial(ctx, "tcp", dataAddr)
+	if err != nil {
+		return nil, 0, fmt.Errorf("не удалось открыть канал данных FTP: %w", err)
+	}
+
+	if _, _, err := c.cmd(1, "RETR %s", filePath); err != nil {
+		data.Close()
+		return nil, 0, fmt.Errorf("файл не найден на FTP-сервере: %w", err)
+	}
+
+	return data, size, nil
+}
+
+// ftpCredentials возвращает логин и пароль из ссылки, из параметров запроса или анонимные
+func ftpCredentials(req *Request) (user, pass string) {
+	user, pass = "anonymous", "anonymous@"
+	if req.URL.User != nil {
+		user = req.URL.User.Username()
+		pass, _ = req.URL.User.Password()
+	} else if req.Username != "" {
+		user, pass = req.Username, req.Password
+	}
+	return user, pass
+}
+
+func hasControlChars(s string) bool {
+	return strings.ContainsAny(s, "\r\n\x00")
+}
+
+// passive возвращает адрес канала данных, сначала через EPSV, затем через PASV
+func (c *ftpConn) passive() (string, error) {
+	if _, msg, err := c.cmd(229, "EPSV"); err == nil {
+		start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if start >= 0 && end > start+4 {
+			return net.JoinHostPort(c.host, msg[start+4:end]), nil
+		}
+	}
+
+	_, msg, err := c.cmd(227, "PASV")
+	if err != nil {
+		return "", fmt.Errorf("FTP-сервер не поддерживает пассивный режим: %w", err)
+	}
+	start, end := strings.Index(msg, "("), strings.Index(msg, ")")
+	if start < 0 || end < start {
+		return "", fmt.Errorf("некорректный ответ PASV: %s", msg)
+	}
+	parts := strings.Split(msg[start+1:end], ",")
+	if len(parts) != 6 {
+		return "", fmt.Errorf("некорректный ответ PASV: %s", msg)
+	}
+	p1, err1 := strconv.Atoi(strings.TrimSpace(parts[4]))
+	p2, err2 := strconv.Atoi(strings.TrimSpace(parts[5]))
+	if err1 != nil || err2 != nil {
+		return "", fmt.Errorf("некорректный ответ PASV: %s", msg)
+	}
+	// Адрес из ответа игнорируется: за NAT сервер часто сообщает внутренний IP
+	return net.JoinHostPort(c.host, strconv.Itoa(p1<<8|p2)), nil
+}
+
+type ftpBody struct {
+	io.ReadCloser
+	conn *ftpConn
+	stop func() bool
+}
+
+func (b *ftpBody) Close() error {
+	err := b.ReadCloser.Close()
+	if _, _, respErr := b.conn.ReadResponse(2); respErr != nil && err == nil {
+		err = respErr
+	}
+	b.conn.Cmd("QUIT")
+	b.stop()
+	b.conn.Close()
+	return err
+}
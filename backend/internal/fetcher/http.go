@@ -0,0 +1,83 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type HTTPFetcher struct {
+	client *http.Client
+}
+
+func NewHTTPFetcher(client *http.Client) *HTTPFetcher {
+	return &HTTPFetcher{client: client}
+}
+
+func (f *HTTPFetcher) Fetch(ctx context.Context, req *Request) (*Response, error) {
+	ctx = withProxy(ctx, req.Proxy)
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodGet, req.URL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	for name, values := range req.Header {
+		httpReq.Header[name] = values
+	}
+	if req.Username != "" || req.Password != "" {
+		httpReq.SetBasicAuth(req.Username, req.Password)
+	}
+
+	return doHTTP(f.client, httpReq)
+}
+
+type StatusError struct {
+	Code int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("файл не найден, статус: %d", e.Code)
+}
+
+// Temporary сообщает, имеет ли смысл повторить запрос
+func (e *StatusError) Temporary() bool {
+	return e.Code >= 500 || e.Code == http.StatusRequestTimeout || e.Code == http.StatusTooManyRequests
+}
+
+func doHTTP(client *http.Client, httpReq *http.Request) (*Response, error) {
+	ctx, trace := withRedirectTrace(httpReq.Context())
+	httpReq = httpReq.WithContext(ctx)
+
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		if len(trace.hops) > 0 {
+			err = fmt.Errorf("%w (редиректы: %s)", err, strings.Join(trace.hops, " -> "))
+		}
+		return nil, classifyProxyError(err)
+	}
+
+	if resp.StatusCode == http.StatusProxyAuthRequired {
+		resp.Body.Close()
+		return nil, fmt.Errorf("%w, статус: %d", ErrProxyAuth, resp.StatusCode)
+	}
+	partial := resp.StatusCode == http.StatusPartialContent && httpReq.Header.Get("Range") != ""
+	if resp.StatusCode != http.StatusOK && !partial {
+		resp.Body.Close()
+		return nil, &StatusError{Code: resp.StatusCode}
+	}
+
+	out := &Response{
+		Body:               resp.Body,
+		ContentLength:      resp.ContentLength,
+		ContentType:        resp.Header.Get("Content-Type"),
+		FinalURL:           resp.Request.URL.Redacted(),
+		Redirects:          trace.hops,
+		Partial:            partial,
+		ContentRange:       resp.Header.Get("Content-Range"),
+		ContentDisposition: resp.Header.Get("Content-Disposition"),
+	}
+	if lm, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		out.LastModified = lm
+	}
+	return out, nil
+}
@@ -0,0 +1,100 @@
+package fetcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Prober - загрузчик, который умеет узнать тип и размер файла, не скачивая его целиком
+type Prober interface {
+	Probe(ctx context.Context, req *Request) (*Response, error)
+}
+
+// Probe проверяет доступность ссылки. Для схем без собственной проверки файл открывается
+// обычным запросом и сразу закрывается. Body в ответе всегда nil
+func (r *Registry) Probe(ctx context.Context, req *Request) (*Response, error) {
+	f, ok := r.fetchers[strings.ToLower(req.URL.Scheme)]
+	if !ok {
+		return nil, fmt.Errorf("неподдерживаемая схема ссылки: %s", req.URL.Scheme)
+	}
+	if prober, ok := f.(Prober); ok {
+		return prober.Probe(ctx, req)
+	}
+
+	resp, err := f.Fetch(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	resp.Body = nil
+	return resp, nil
+}
+
+// Probe отправляет HEAD, а если сервер его не поддерживает или не сообщил размер -
+// GET первого байта (Range: bytes=0-0), размер тогда берётся из Content-Range
+func (f *HTTPFetcher) Probe(ctx context.Context, req *Request) (*Response, error) {
+	resp, err := f.probe(ctx, req, http.MethodHead)
+	var status *StatusError
+	switch {
+	case err == nil && resp.ContentLength >= 0:
+		return resp, nil
+	case err != nil && (!errors.As(err, &status) || status.Code == http.StatusNotFound || status.Code == http.StatusGone):
+		return nil, err
+	}
+
+	ranged, rangeErr := f.probe(ctx, req, http.MethodGet)
+	if rangeErr != nil {
+		if err == nil {
+			return resp, nil
+		}
+		return nil, rangeErr
+	}
+	return ranged, nil
+}
+
+func (f *HTTPFetcher) probe(ctx context.Context, req *Request, method string) (*Response, error) {
+	ctx = withProxy(ctx, req.Proxy)
+	httpReq, err := http.NewRequestWithContext(ctx, method, req.URL.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать запрос: %w", err)
+	}
+	for name, values := range req.Header {
+		httpReq.Header[name] = values
+	}
+	if req.Username != "" || req.Password != "" {
+		httpReq.SetBasicAuth(req.Username, req.Password)
+	}
+	if method == http.MethodGet {
+		httpReq.Header.Set("Range", "bytes=0-0")
+	}
+
+	resp, err := doHTTP(f.client, httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Partial {
+		resp.ContentLength = rangeTotal(resp.ContentRange)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1))
+	resp.Body.Close()
+	resp.Body = nil
+	return resp, nil
+}
+
+// rangeTotal возвращает полный размер из заголовка Content-Range (bytes 0-0/1234) или -1
+func rangeTotal(contentRange string) int64 {
+	_, total, ok := strings.Cut(contentRange, "/")
+	if !ok {
+		return -1
+	}
+	size, err := strconv.ParseInt(strings.TrimSpace(total), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return size
+}
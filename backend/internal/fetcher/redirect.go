@@ -0,0 +1,77 @@
+package fetcher
+
+import (
+	"backend/internal/config"
+	"backend/internal/repository"
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+type redirectKey struct{}
+
+// redirectTrace собирает цепочку редиректов одного запроса
+type redirectTrace struct {
+	hops []string
+}
+
+func withRedirectTrace(ctx context.Context) (context.Context, *redirectTrace) {
+	trace := &redirectTrace{}
+	return context.WithValue(ctx, redirectKey{}, trace), trace
+}
+
+type redirectPolicy struct {
+	cfg config.Redirects
+}
+
+func (p redirectPolicy) check(req *http.Request, via []*http.Request) error {
+	if trace, ok := req.Context().Value(redirectKey{}).(*redirectTrace); ok {
+		trace.hops = append(trace.hops, req.URL.Redacted())
+	}
+
+	if len(via) > p.cfg.MaxHops {
+		return fmt.Errorf("превышено количество редиректов: %d", p.cfg.MaxHops)
+	}
+
+	prev := via[len(via)-1]
+	if p.cfg.ForbidDowngrade && prev.URL.Scheme == "https" && req.URL.Scheme != "https" {
+		return fmt.Errorf("редирект с https на %s запрещён: %s", req.URL.Scheme, req.URL.Redacted())
+	}
+
+	// net/http убирает при смене домена только Authorization и Cookie, а секреты
+	// из пользовательских заголовков уходят только на исходный хост
+	if !strings.EqualFold(req.URL.Host, via[0].URL.Host) {
+		for name := range req.Header {
+			if repository.IsSensitiveHeader(name) {
+				req.Header.Del(name)
+			}
+		}
+	}
+
+	if !p.cfg.SameDomain && len(p.cfg.AllowedHosts) == 0 {
+		return nil
+	}
+	host := strings.ToLower(req.URL.Hostname())
+	for _, pattern := range p.cfg.AllowedHosts {
+		if ok, _ := path.Match(strings.ToLower(strings.TrimSpace(pattern)), host); ok {
+			return nil
+		}
+	}
+	if p.cfg.SameDomain && registeredDomain(host) == registeredDomain(via[0].URL.Hostname()) {
+		return nil
+	}
+	return fmt.Errorf("редирект на хост %s запрещён политикой", host)
+}
+
+func registeredDomain(host string) string {
+	host = strings.ToLower(host)
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		return host
+	}
+	return domain
+}
@@ -0,0 +1,181 @@
+package fetcher
+
+import (
+	"backend/internal/config"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func newRedirectTestClient(cfg config.Redirects) *http.Client {
+	return &http.Client{CheckRedirect: redirectPolicy{cfg: cfg}.check}
+}
+
+func TestRedirectDropsSecretHeaders(t *testing.T) {
+	seen := make(chan http.Header, 2)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen <- r.Header.Clone()
+		io.WriteString(w, "ok")
+	}))
+	defer target.Close()
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/away":
+			http.Redirect(w, r, target.URL+"/file.pdf", http.StatusFound)
+		case "/here":
+			http.Redirect(w, r, "/file.pdf", http.StatusFound)
+		default:
+			seen <- r.Header.Clone()
+			io.WriteString(w, "ok")
+		}
+	}))
+	defer origin.Close()
+
+	f := NewHTTPFetcher(newRedirectTestClient(config.Redirects{MaxHops: 5}))
+	fetch := func(p string) http.Header {
+		t.Helper()
+		u, _ := url.Parse(origin.URL + p)
+		resp, err := f.Fetch(context.Background(), &Request{
+			URL: u,
+			Header: http.Header{
+				"X-Api-Key":     {"key"},
+				"Private-Token": {"token"},
+				"Accept":        {"application/pdf"},
+			},
+			Username: "user",
+			Password: "pass",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return <-seen
+	}
+
+	got := fetch("/away")
+	for _, name := range []string{"X-Api-Key", "Private-Token", "Authorization"} {
+		if v := got.Get(name); v != "" {
+			t.Errorf("заголовок %s ушёл на чужой хост: %q", name, v)
+		}
+	}
+	if got.Get("Accept") != "application/pdf" {
+		t.Errorf("обычный заголовок потерян: %v", got)
+	}
+
+	got = fetch("/here")
+	for _, name := range []string{"X-Api-Key", "Private-Token", "Authorization"} {
+		if got.Get(name) == "" {
+			t.Errorf("заголовок %s потерян при редиректе на тот же хост", name)
+		}
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	newReq := func(link string) *http.Request {
+		req, err := http.NewRequest(http.MethodGet, link, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	via := func(links ...string) []*http.Request {
+		out := make([]*http.Request, len(links))
+		for i, link := range links {
+			out[i] = newReq(link)
+		}
+		return out
+	}
+
+	for _, tc := range []struct {
+		name string
+		cfg  config.Redirects
+		to   string
+		via  []*http.Request
+		ok   bool
+	}{
+		{"в пределах лимита", config.Redirects{MaxHops: 2}, "https://a.example.com/3", via("https://a.example.com/1", "https://a.example.com/2"), true},
+		{"лимит превышен", config.Redirects{MaxHops: 2}, "https://a.example.com/4", via("https://a.example.com/1", "https://a.example.com/2", "https://a.example.com/3"), false},
+		{"https на http запрещён", config.Redirects{MaxHops: 10, ForbidDowngrade: true}, "http://a.example.com/", via("https://a.example.com/"), false},
+		{"https на http разрешён", config.Redirects{MaxHops: 10}, "http://a.example.com/", via("https://a.example.com/"), true},
+		{"http на https", config.Redirects{MaxHops: 10, ForbidDowngrade: true}, "https://a.example.com/", via("http://a.example.com/"), true},
+		{"понижение после промежуточного хопа", config.Redirects{MaxHops: 10, ForbidDowngrade: true}, "http://a.example.com/", via("http://a.example.com/", "https://a.example.com/"), false},
+		{"без политики любой хост", config.Redirects{MaxHops: 10}, "https://other.org/", via("https://a.example.com/"), true},
+		{"тот же домен", config.Redirects{MaxHops: 10, SameDomain: true}, "https://cdn.example.com/", via("https://dl.example.com/"), true},
+		{"тот же домен, регистр", config.Redirects{MaxHops: 10, SameDomain: true}, "https://CDN.Example.COM/", via("https://dl.example.com/"), true},
+		{"другой домен", config.Redirects{MaxHops: 10, SameDomain: true}, "https://example.org/", via("https://dl.example.com/"), false},
+		{"сравнение с исходной ссылкой", config.Redirects{MaxHops: 10, SameDomain: true}, "https://b.example.org/", via("https://dl.example.com/", "https://a.example.org/"), false},
+		{"публичный суффикс", config.Redirects{MaxHops: 10, SameDomain: true}, "https://evil.github.io/", via("https://good.github.io/"), false},
+		{"составной суффикс", config.Redirects{MaxHops: 10, SameDomain: true}, "https://files.example.co.uk/", via("https://www.example.co.uk/"), true},
+		{"хост из списка", config.Redirects{MaxHops: 10, AllowedHosts: []string{"*.cdn.net"}}, "https://eu.cdn.net/", via("https://dl.example.com/"), true},
+		{"хост из списка, пробелы и регистр", config.Redirects{MaxHops: 10, AllowedHosts: []string{" *.CDN.net "}}, "https://eu.cdn.net/", via("https://dl.example.com/"), true},
+		{"хост не из списка", config.Redirects{MaxHops: 10, AllowedHosts: []string{"*.cdn.net"}}, "https://cdn.net.evil.org/", via("https://dl.example.com/"), false},
+		{"список не покрывает исходный домен", config.Redirects{MaxHops: 10, AllowedHosts: []string{"*.cdn.net"}}, "https://cdn.example.com/", via("https://dl.example.com/"), false},
+		{"список и тот же домен", config.Redirects{MaxHops: 10, SameDomain: true, AllowedHosts: []string{"*.cdn.net"}}, "https://cdn.example.com/", via("https://dl.example.com/"), true},
+		{"список не отменяет запрет понижения", config.Redirects{MaxHops: 10, ForbidDowngrade: true, AllowedHosts: []string{"*.cdn.net"}}, "http://eu.cdn.net/", via("https://dl.example.com/"), false},
+	} {
+		err := redirectPolicy{cfg: tc.cfg}.check(newReq(tc.to), tc.via)
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: ошибка = %v, ожидалось разрешение: %v", tc.name, err, tc.ok)
+		}
+	}
+}
+
+func TestRedirectPolicyOverHTTP(t *testing.T) {
+	plain := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// /countdown/N отвечает N редиректами подряд
+		if n, ok := strings.CutPrefix(r.URL.Path, "/countdown/"); ok && n != "0" {
+			var i int
+			fmt.Sscan(n, &i)
+			http.Redirect(w, r, fmt.Sprintf("/countdown/%d", i-1), http.StatusFound)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer plain.Close()
+	secure := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, plain.URL+"/file.pdf", http.StatusFound)
+	}))
+	defer secure.Close()
+
+	for _, tc := range []struct {
+		name    string
+		cfg     config.Redirects
+		link    string
+		wantErr string
+		hops    int
+	}{
+		{"цепочка в пределах лимита", config.Redirects{MaxHops: 3}, plain.URL + "/countdown/3", "", 3},
+		{"лимит редиректов", config.Redirects{MaxHops: 3}, plain.URL + "/countdown/4", "превышено количество редиректов", 4},
+		{"понижение до http", config.Redirects{MaxHops: 3, ForbidDowngrade: true}, secure.URL + "/file.pdf", "редирект с https на http запрещён", 1},
+		{"понижение разрешено", config.Redirects{MaxHops: 3}, secure.URL + "/file.pdf", "", 1},
+	} {
+		client := &http.Client{
+			Transport:     secure.Client().Transport,
+			CheckRedirect: redirectPolicy{cfg: tc.cfg}.check,
+		}
+		u, _ := url.Parse(tc.link)
+		resp, err := NewHTTPFetcher(client).Fetch(context.Background(), &Request{URL: u})
+		if tc.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("%s: ошибка = %v, ожидалось %q", tc.name, err, tc.wantErr)
+			}
+			if err != nil && strings.Count(err.Error(), "->")+1 != tc.hops {
+				t.Errorf("%s: цепочка редиректов в ошибке: %v", tc.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+			continue
+		}
+		resp.Body.Close()
+		if len(resp.Redirects) != tc.hops {
+			t.Errorf("%s: редиректы = %v, ожидалось %d", tc.name, resp.Redirects, tc.hops)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package fetcher
+
+import (
+	"backend/internal/config"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// verifySigV4 независимо пересчитывает подпись AWS Signature V4 запроса, пришедшего на сервер
+func verifySigV4(r *http.Request, accessKey, secretKey, region string) string {
+	auth := r.Header.Get("Authorization")
+	fields := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ", ") {
+		k, v, _ := strings.Cut(part, "=")
+		fields[k] = v
+	}
+	credential := strings.SplitN(fields["Credential"], "/", 2)
+	if len(credential) != 2 || credential[0] != accessKey {
+		return "неверный ключ доступа в Credential: " + fields["Credential"]
+	}
+	scope := credential[1]
+	date, _, _ := strings.Cut(scope, "/")
+	if scope != date+"/"+region+"/s3/aws4_request" {
+		return "неверная область подписи: " + scope
+	}
+
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(fields["SignedHeaders"], ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	escapedPath, query, _ := strings.Cut(r.RequestURI, "?")
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	canonicalRequest := strings.Join([]string{
+		r.Method, escapedPath, query, canonicalHeaders.String(), fields["SignedHeaders"], payloadHash,
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + r.Header.Get("X-Amz-Date") + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	if want := hex.EncodeToString(hmacSHA256(key, stringToSign)); fields["Signature"] != want {
+		return "подпись не совпадает"
+	}
+	if _, err := time.Parse("20060102T150405Z", r.Header.Get("X-Amz-Date")); err != nil {
+		return "некорректный X-Amz-Date"
+	}
+	return ""
+}
+
+func TestS3FetcherSignsRequest(t *testing.T) {
+	const accessKey, secretKey, region = "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "eu-central-1"
+	var gotPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if problem := verifySigV4(r, accessKey, secretKey, region); problem != "" {
+			http.Error(w, problem, http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/pdf")
+		io.WriteString(w, "object body")
+	}))
+	defer server.Close()
+
+	f := NewS3Fetcher(server.Client(), config.S3{
+		Endpoint:  server.URL,
+		Region:    region,
+		AccessKey: accessKey,
+		SecretKey: secretKey,
+		PathStyle: true,
+	})
+	u, _ := url.Parse("s3://bucket/reports/отчёт 2024.pdf")
+	resp, err := f.Fetch(context.Background(), &Request{URL: u})
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "object body" {
+		t.Errorf("тело = %q", body)
+	}
+	if gotPath != "/bucket/reports/отчёт 2024.pdf" {
+		t.Errorf("путь на сервере = %q", gotPath)
+	}
+	if resp.ContentType != "application/pdf" {
+		t.Errorf("ContentType = %q", resp.ContentType)
+	}
+}
+
+func TestS3FetcherRejectsWrongSecret(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if problem := verifySigV4(r, "AKID", "right-secret", "us-east-1"); problem != "" {
+			http.Error(w, problem, http.StatusForbidden)
+			return
+		}
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+
+	f := NewS3Fetcher(server.Client(), config.S3{Endpoint: server.URL, Region: "us-east-1", AccessKey: "AKID", SecretKey: "wrong-secret", PathStyle: true})
+	u, _ := url.Parse("s3://bucket/key")
+	if _, err := f.Fetch(context.Background(), &Request{URL: u}); err == nil {
+		t.Fatal("запрос с неверным секретом не отклонён")
+	}
+}
@@ -0,0 +1,109 @@
+package fetcher
+
+import (
+	"backend/internal/config"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+)
+
+type hostTransport struct {
+	pattern   string
+	insecure  bool
+	transport *http.Transport
+}
+
+// tlsRouter выбирает транспорт с нужными TLS-настройками по имени хоста.
+// Редиректы проходят через клиент заново, поэтому каждый переход маршрутизируется отдельно.
+type tlsRouter struct {
+	base  *http.Transport
+	hosts []hostTransport
+	log   *slog.Logger
+}
+
+func (t *tlsRouter) RoundTrip(req *http.Request) (*http.Response, error) {
+	host := strings.ToLower(req.URL.Hostname())
+	for _, h := range t.hosts {
+		if ok, _ := path.Match(h.pattern, host); !ok {
+			continue
+		}
+		if h.insecure && req.URL.Scheme == "https" {
+			t.log.Warn("ПРОВЕРКА TLS-СЕРТИФИКАТА ОТКЛЮЧЕНА", slog.String("host", host), slog.String("pattern", h.pattern))
+		}
+		return h.transport.RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+func newTLSRouter(base *http.Transport, cfg config.TLS, log *slog.Logger) (http.RoundTripper, error) {
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	for _, file := range cfg.CAFiles {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать CA-бандл %s: %w", file, err)
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("в файле %s не найдено ни одного сертификата", file)
+		}
+	}
+
+	baseTLS := &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}
+	base.TLSClientConfig = baseTLS
+
+	router := &tlsRouter{base: base, log: log}
+	index := make(map[string]int)
+	hostFor := func(pattern string) *hostTransport {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if i, ok := index[pattern]; ok {
+			return &router.hosts[i]
+		}
+		transport := base.Clone()
+		transport.TLSClientConfig = baseTLS.Clone()
+		index[pattern] = len(router.hosts)
+		router.hosts = append(router.hosts, hostTransport{pattern: pattern, transport: transport})
+		return &router.hosts[len(router.hosts)-1]
+	}
+
+	for _, entry := range cfg.ClientCerts {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		pattern, files, ok := strings.Cut(entry, "=")
+		certFile, keyFile, ok2 := strings.Cut(files, ":")
+		if !ok || !ok2 || pattern == "" {
+			return nil, fmt.Errorf("клиентский сертификат должен иметь формат host_pattern=cert.pem:key.pem, получено %q", entry)
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось загрузить клиентский сертификат для %s: %w", pattern, err)
+		}
+		h := hostFor(pattern)
+		h.transport.TLSClientConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	for _, pattern := range cfg.InsecureSkipVerifyHosts {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		h := hostFor(pattern)
+		h.insecure = true
+		h.transport.TLSClientConfig.InsecureSkipVerify = true
+		log.Warn("ВНИМАНИЕ: проверка TLS-сертификатов отключена, используйте только в тестовом окружении",
+			slog.String("pattern", h.pattern))
+	}
+
+	return router, nil
+}
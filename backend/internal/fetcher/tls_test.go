@@ -0,0 +1,138 @@
+package fetcher
+
+import (
+	"backend/internal/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeClientCert создаёт самоподписанный клиентский сертификат и возвращает пути к PEM-файлам
+func writeClientCert(t *testing.T) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "downloader"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	certFile, keyFile = filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestTLSRouterHostMatching(t *testing.T) {
+	plain := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer plain.Close()
+
+	mtls := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	mtls.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	mtls.StartTLS()
+	defer mtls.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: plain.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile := writeClientCert(t)
+	port := func(s *httptest.Server) string { return s.URL[strings.LastIndex(s.URL, ":"):] }
+
+	// сертификат httptest выписан на 127.0.0.1 и example.com, но не на localhost
+	for _, tc := range []struct {
+		name string
+		cfg  config.TLS
+		link string
+		ok   bool
+	}{
+		{"недоверенный сертификат", config.TLS{}, plain.URL, false},
+		{"доверенный CA", config.TLS{CAFiles: []string{caFile}}, plain.URL, true},
+		{"CA не отменяет проверку имени", config.TLS{CAFiles: []string{caFile}}, "https://localhost" + port(plain), false},
+		{"проверка отключена для хоста", config.TLS{InsecureSkipVerifyHosts: []string{"127.0.0.1"}}, plain.URL, true},
+		{"маска и регистр", config.TLS{InsecureSkipVerifyHosts: []string{" LOCAL* "}}, "https://localhost" + port(plain), true},
+		{"маска не совпала", config.TLS{InsecureSkipVerifyHosts: []string{"localhost"}}, plain.URL, false},
+		{"маска не заходит в поддомены", config.TLS{InsecureSkipVerifyHosts: []string{"*.localhost"}}, "https://localhost" + port(plain), false},
+		{"клиентский сертификат", config.TLS{
+			ClientCerts:             []string{"127.0.0.1=" + certFile + ":" + keyFile},
+			InsecureSkipVerifyHosts: []string{"127.0.0.1"},
+		}, mtls.URL, true},
+		{"клиентский сертификат другому хосту", config.TLS{
+			ClientCerts:             []string{"*.corp.local=" + certFile + ":" + keyFile},
+			InsecureSkipVerifyHosts: []string{"127.0.0.1"},
+		}, mtls.URL, false},
+		{"настройки хостов не смешиваются", config.TLS{
+			ClientCerts:             []string{"localhost=" + certFile + ":" + keyFile},
+			InsecureSkipVerifyHosts: []string{"127.0.0.1"},
+		}, "https://localhost" + port(mtls), false},
+	} {
+		router, err := newTLSRouter(http.DefaultTransport.(*http.Transport).Clone(), tc.cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		resp, err := (&http.Client{Transport: router}).Get(tc.link)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != tc.ok {
+			t.Errorf("%s: ошибка = %v, ожидался успех: %v", tc.name, err, tc.ok)
+		}
+	}
+}
+
+func TestTLSRouterConfigErrors(t *testing.T) {
+	certFile, keyFile := writeClientCert(t)
+	missing := filepath.Join(t.TempDir(), "missing.pem")
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, cfg := range map[string]config.TLS{
+		"нет файла CA":            {CAFiles: []string{missing}},
+		"в файле нет сертификата": {CAFiles: []string{empty}},
+		"нет маски хоста":         {ClientCerts: []string{"=" + certFile + ":" + keyFile}},
+		"нет ключа":               {ClientCerts: []string{"host=" + certFile}},
+		"нет разделителя":         {ClientCerts: []string{certFile + ":" + keyFile}},
+		"ключ не читается":        {ClientCerts: []string{"host=" + certFile + ":" + missing}},
+	} {
+		if _, err := newTLSRouter(http.DefaultTransport.(*http.Transport).Clone(), cfg, slog.New(slog.NewTextHandler(io.Discard, nil))); err == nil {
+			t.Errorf("%s: ошибка не возвращена", name)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package fetcher
+
+import (
+	"backend/internal/config"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+var (
+	ErrProxyAuth    = errors.New("ошибка авторизации на прокси-сервере")
+	ErrProxyConnect = errors.New("ошибка подключения к прокси-серверу")
+)
+
+type proxyKey struct{}
+
+// withProxy задаёт прокси для одного запроса, перекрывая настройки из конфига
+func withProxy(ctx context.Context, proxy *url.URL) context.Context {
+	if proxy == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, proxyKey{}, proxy)
+}
+
+func newTransport(cfg config.Proxy) *http.Transport {
+	proxyCfg := httpproxy.Config{
+		HTTPProxy:  cfg.HTTP,
+		HTTPSProxy: cfg.HTTPS,
+		NoProxy:    cfg.NoProxy,
+	}
+	if proxyCfg.HTTPProxy == "" {
+		proxyCfg.HTTPProxy = cfg.SOCKS5
+	}
+	if proxyCfg.HTTPSProxy == "" {
+		proxyCfg.HTTPSProxy = cfg.SOCKS5
+	}
+	proxyFunc := proxyCfg.ProxyFunc()
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if proxy, ok := req.Context().Value(proxyKey{}).(*url.URL); ok {
+			return proxy, nil
+		}
+		return proxyFunc(req.URL)
+	}
+	return transport
+}
+
+// classifyProxyError выделяет ошибки прокси среди прочих сетевых ошибок
+func classifyProxyError(err error) error {
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "Proxy Authentication Required"),
+		strings.Contains(msg, "socks") && strings.Contains(msg, "authentication"):
+		return fmt.Errorf("%w: %v", ErrProxyAuth, err)
+	case strings.Contains(msg, "proxyconnect") || strings.Contains(msg, "socks connect"):
+		return fmt.Errorf("%w: %v", ErrProxyConnect, err)
+	}
+	return err
+}
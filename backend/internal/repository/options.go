@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"net/url"
+	"strings"
+)
+
+const redacted = "***"
+
+// RequestOptions - параметры запроса, с которыми скачивается файл
+type RequestOptions struct {
+	Headers     map[string]string `json:"headers,omitempty"`
+	Cookies     map[string]string `json:"cookies,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Password    string            `json:"password,omitempty"`
+	BearerToken string            `json:"bearer_token,omitempty"`
+	UserAgent   string            `json:"user_agent,omitempty"`
+	Proxy       string            `json:"proxy,omitempty"`
+}
+
+var sensitiveHeaderParts = []string{"auth", "token", "secret", "key", "cookie", "session"}
+
+// Redacted возвращает копию параметров, в которой пароли, токены, куки и
+// чувствительные заголовки заменены на ***
+func (o RequestOptions) Redacted() RequestOptions {
+	out := RequestOptions{
+		Username:  o.Username,
+		UserAgent: o.UserAgent,
+		Proxy:     redactURL(o.Proxy),
+	}
+	if o.Password != "" {
+		out.Password = redacted
+	}
+	if o.BearerToken != "" {
+		out.BearerToken = redacted
+	}
+	if len(o.Cookies) > 0 {
+		out.Cookies = make(map[string]string, len(o.Cookies))
+		for name := range o.Cookies {
+			out.Cookies[name] = redacted
+		}
+	}
+	if len(o.Headers) > 0 {
+		out.Headers = make(map[string]string, len(o.Headers))
+		for name, value := range o.Headers {
+			if IsSensitiveHeader(name) {
+				value = redacted
+			}
+			out.Headers[name] = value
+		}
+	}
+	return out
+}
+
+// WithoutCredentials возвращает копию параметров без логина, пароля, токена, кук и
+// чувствительных заголовков - для запросов к чужим хостам
+func (o RequestOptions) WithoutCredentials() RequestOptions {
+	out := RequestOptions{UserAgent: o.UserAgent, Proxy: o.Proxy}
+	for name, value := range o.Headers {
+		if IsSensitiveHeader(name) {
+			continue
+		}
+		if out.Headers == nil {
+			out.Headers = make(map[string]string, len(o.Headers))
+		}
+		out.Headers[name] = value
+	}
+	return out
+}
+
+func redactURL(link string) string {
+	if link == "" {
+		return ""
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return redacted
+	}
+	return u.Redacted()
+}
+
+// IsSensitiveHeader сообщает, может ли заголовок нести секрет (токен, ключ, куки)
+func IsSensitiveHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, part := range sensitiveHeaderParts {
+		if strings.Contains(name, part) {
+			return true
+		}
+	}
+	return false
+}
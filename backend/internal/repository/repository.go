@@ -1,14 +1,17 @@
 package repository
 
 type Tasks interface {
-	CreateTask() (int64, error)
-	AppendLink(id int64, link string) error
-	AppendLoadedFileLink(id int64, link string) error
+	CreateTask(archive ArchiveOptions) (int64, error)
+	AppendFile(id int64, file File) (int, error)
+	UpdateFile(id int64, idx int, update func(file *File)) error
 	GetTask(id int64) (Task, error)
 	UpdateTaskStatus(id int64, status string) error
 	CountActiveTasks() int8
 	AppendError(id int64, err string) error
-	UpdateArchiveName(id int64, archiveName string) error
+	SaveArchive(id int64, info ArchiveInfo) error
+	SealTask(id int64) error
+	UpdatePendingJobs(id int64, delta int) error
+	StartArchiving(id int64) (Task, bool, error)
 }
 
 type Repositories struct {
@@ -3,22 +3,155 @@ package repository
 import (
 	"fmt"
 	"sync"
+	"time"
 )
 
 const (
 	TaskCreated    string = "Создано"
 	TaskProcessing string = "Обрабатывается"
+	TaskArchiving  string = "Архивируется"
 	TaskCompleted  string = "Выполнено"
 	TaskFailed     string = "Ошибка"
 )
 
+const (
+	FileQueued string = "В очереди"
+	FileLoaded string = "Загружен"
+	FileFailed string = "Ошибка"
+)
+
 type Task struct {
-	Id               int64    `json:"-"`
-	Status           string   `json:"status"`
-	Links            []string `json:"-"`
-	LoadedFilesLinks []string `json:"-"`
-	ArchivePath      string   `json:"-"`
-	Errors           []string `json:"errors,omitempty"`
+	Id          int64    `json:"-"`
+	Status      string   `json:"status"`
+	Files       []File   `json:"files,omitempty"`
+	ArchivePath string   `json:"-"`
+	Errors      []string `json:"errors,omitempty"`
+	// CreatedAt - время создания задачи, FinishedAt - время, когда обработаны все файлы
+	CreatedAt  time.Time `json:"created_at"`
+	FinishedAt time.Time `json:"finished_at,omitzero"`
+	// Archive - параметры архива, заданные при создании задачи
+	Archive ArchiveOptions `json:"archive"`
+	// ArchiveInfo - сведения об основном архиве, появляются, когда архив можно скачать
+	ArchiveInfo *ArchiveInfo `json:"archive_info,omitempty"`
+	// Archives - архивы в других форматах, собранные по запросу, по формату
+	Archives map[string]ArchiveInfo `json:"archives,omitempty"`
+	// Sealed - новых файлов в задаче больше не ожидается (достигнут лимит или задача создана одним запросом)
+	Sealed bool `json:"-"`
+	// PendingJobs - число фоновых разборов страниц и лент, которые ещё могут добавить файлы
+	PendingJobs int `json:"-"`
+	// Finalized - сборка архива уже запущена, повторно она не выполняется
+	Finalized bool `json:"-"`
+}
+
+// ArchiveOptions - параметры архива задачи
+type ArchiveOptions struct {
+	// Format - формат архива: zip, tar, tar.gz или tar.zst
+	Format string `json:"format,omitempty" enums:"zip,tar,tar.gz,tar.zst"`
+	// Compression - сжатие записей zip: auto - по пробе содержимого, deflate - всё, кроме уже сжатых типов, store - без сжатия
+	Compression string `json:"compression,omitempty" enums:"auto,deflate,store"`
+	// CompressionLevel - уровень сжатия 1-9 для deflate, gzip и zstd
+	CompressionLevel int `json:"compression_level,omitempty"`
+	// Mode - file: архив собирается на диске после загрузки файлов, stream: собирается при каждом скачивании
+	Mode string `json:"mode,omitempty" enums:"file,stream"`
+	// Layout - раскладка файлов по папкам: flat - все в корне, host - по хосту ссылки, type - по типу содержимого
+	Layout string `json:"layout,omitempty" enums:"flat,host,type"`
+	// Passphrase - пароль для шифрования архива, приходит только в запросе: в задаче хранится
+	// SealedPassphrase, зашифрованный ключом, который есть только в памяти сервиса
+	Passphrase       string `json:"passphrase,omitempty"`
+	SealedPassphrase []byte `json:"-"`
+	// Recipient - открытый ключ age (age1...), которым шифруются tar-архивы
+	Recipient string `json:"recipient,omitempty"`
+	Encrypted bool   `json:"encrypted,omitempty"`
+}
+
+// ArchiveInfo - сведения о собранном архиве. Ratio - отношение размера архива к суммарному размеру файлов.
+// У потокового архива Path пуст, а Size известен, только если файлы не сжимаются или архив не зашифрован.
+// Checksum - SHA-256 архива в виде sha256:hex, у зашифрованного потокового архива её нет.
+// Signature - подпись ed25519 контрольной суммы, если на сервере задан ключ подписи
+type ArchiveInfo struct {
+	Path         string         `json:"-"`
+	Format       string         `json:"format"`
+	Size         int64          `json:"size,omitempty"`
+	Checksum     string         `json:"checksum,omitempty"`
+	Signature    []byte         `json:"signature,omitempty"`
+	OriginalSize int64          `json:"original_size"`
+	Ratio        float64        `json:"ratio,omitempty"`
+	Entries      []ArchiveEntry `json:"entries,omitempty"`
+}
+
+// ArchiveEntry - файл внутри архива. Method и CompressedSize известны только для zip,
+// tar.gz и tar.zst сжимаются целиком
+type ArchiveEntry struct {
+	Name           string  `json:"name"`
+	Size           int64   `json:"size"`
+	Method         string  `json:"method,omitempty" enums:"store,deflate"`
+	CompressedSize int64   `json:"compressed_size,omitempty"`
+	Ratio          float64 `json:"ratio,omitempty"`
+}
+
+// File - запись о файле задачи. Link и Options хранятся в отредактированном виде,
+// настоящие секреты есть только у горутины, которая скачивает файл.
+type File struct {
+	Link    string         `json:"link"`
+	Mirrors []string       `json:"mirrors,omitempty"`
+	Options RequestOptions `json:"-"`
+	Status  string         `json:"status"`
+	// Name - имя файла внутри архива, Folder - папка, заданная клиентом: она важнее раскладки архива
+	Name   string `json:"name,omitempty"`
+	Folder string `json:"folder,omitempty"`
+	Path   string `json:"-"`
+	Error  string `json:"error,omitempty"`
+	// Source - ссылка (основная или одно из зеркал), с которой файл удалось скачать
+	Source string `json:"source,omitempty"`
+	// FinalURL - адрес, с которого файл фактически скачан, Redirects - все промежуточные переходы
+	FinalURL  string   `json:"final_url,omitempty"`
+	Redirects []string `json:"redirects,omitempty"`
+	// ExpectedChecksum - контрольная сумма, переданная клиентом, в виде algo:hex.
+	// Checksum - SHA-256 скачанного файла, считается всегда. ActualChecksum - сумма скачанного
+	// файла в алгоритме ExpectedChecksum, заполняется, только если клиент передал сумму
+	ExpectedChecksum string `json:"expected_checksum,omitempty"`
+	Checksum         string `json:"checksum,omitempty"`
+	ActualChecksum   string `json:"actual_checksum,omitempty"`
+	// ContentType, Size и LastModified - тип, размер и время изменения файла по данным сервера
+	ContentType  string    `json:"content_type,omitempty"`
+	Size         int64     `json:"size,omitempty"`
+	LastModified time.Time `json:"last_modified,omitzero"`
+	// Key - нормализованная ссылка для поиска повторов, Aliases - повторные ссылки, объединённые с этим файлом
+	Key     string   `json:"-"`
+	Aliases []string `json:"aliases,omitempty"`
+	// OriginalLink - ссылка до применения правила переписывания RewriteRule
+	OriginalLink string `json:"original_link,omitempty"`
+	RewriteRule  string `json:"rewrite_rule,omitempty"`
+}
+
+func (t Task) LoadedFiles() []File {
+	var files []File
+	for _, file := range t.Files {
+		if file.Status == FileLoaded {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+func (t Task) CountFinishedFiles() int {
+	count := 0
+	for _, file := range t.Files {
+		if file.Status == FileLoaded || file.Status == FileFailed {
+			count++
+		}
+	}
+	return count
+}
+
+// ArchiveReady сообщает, что архив задачи можно скачивать
+func (t Task) ArchiveReady() bool {
+	return t.ArchiveInfo != nil && t.Status != TaskArchiving
+}
+
+// IsFinished сообщает, что все ожидаемые файлы задачи обработаны
+func (t Task) IsFinished() bool {
+	return t.Sealed && t.PendingJobs == 0 && len(t.Files) > 0 && t.CountFinishedFiles() == len(t.Files)
 }
 
 type TasksRepository struct {
@@ -33,7 +166,7 @@ func NewTasksRepository() *TasksRepository {
 	// return &TasksRepository{semaphore: make(chan struct{}, 3), tasks: make(map[int64]Task)}
 }
 
-func (r *TasksRepository) CreateTask() (int64, error) {
+func (r *TasksRepository) CreateTask(archive ArchiveOptions) (int64, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -46,27 +179,29 @@ func (r *TasksRepository) CreateTask() (int64, error) {
 	}
 
 	r.tasks[id] = Task{
-		Id:     id,
-		Status: TaskCreated,
+		Id:        id,
+		Status:    TaskCreated,
+		Archive:   archive,
+		CreatedAt: time.Now(),
 	}
 	return id, nil
 }
 
-func (r *TasksRepository) AppendLink(id int64, link string) error {
+func (r *TasksRepository) AppendFile(id int64, file File) (int, error) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	task, exists := r.tasks[id]
 	if !exists {
-		return fmt.Errorf("задача с идентификатором %d не найдена", id)
+		return -1, fmt.Errorf("задача с идентификатором %d не найдена", id)
 	}
 
-	task.Links = append(task.Links, link)
+	task.Files = append(task.Files, file)
 	r.tasks[id] = task
-	return nil
+	return len(task.Files) - 1, nil
 }
 
-func (r *TasksRepository) AppendLoadedFileLink(id int64, link string) error {
+func (r *TasksRepository) UpdateFile(id int64, idx int, update func(file *File)) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -74,8 +209,15 @@ func (r *TasksRepository) AppendLoadedFileLink(id int64, link string) error {
 	if !exists {
 		return fmt.Errorf("задача с идентификатором %d не найдена", id)
 	}
+	if idx < 0 || idx >= len(task.Files) {
+		return fmt.Errorf("файл %d в задаче %d не найден", idx, id)
+	}
 
-	task.LoadedFilesLinks = append(task.LoadedFilesLinks, link)
+	// Копируем срез, чтобы не менять задачи, уже отданные через GetTask
+	files := make([]File, len(task.Files))
+	copy(files, task.Files)
+	update(&files[idx])
+	task.Files = files
 	r.tasks[id] = task
 	return nil
 }
@@ -134,7 +276,9 @@ func (r *TasksRepository) CountActiveTasks() int8 {
 	return int8(count)
 }
 
-func (r *TasksRepository) UpdateArchiveName(id int64, archiveName string) error {
+// SaveArchive сохраняет собранный архив. Архив в формате задачи становится основным,
+// остальные форматы запоминаются в Archives
+func (r *TasksRepository) SaveArchive(id int64, info ArchiveInfo) error {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
@@ -143,7 +287,67 @@ func (r *TasksRepository) UpdateArchiveName(id int64, archiveName string) error
 		return fmt.Errorf("задача с идентификатором %d не найдена", id)
 	}
 
-	task.ArchivePath = archiveName
+	if info.Format == task.Archive.Format {
+		task.ArchivePath = info.Path
+		task.ArchiveInfo = &info
+	} else {
+		// копируем карту, чтобы не менять задачи, уже отданные через GetTask
+		archives := make(map[string]ArchiveInfo, len(task.Archives)+1)
+		for format, archive := range task.Archives {
+			archives[format] = archive
+		}
+		archives[info.Format] = info
+		task.Archives = archives
+	}
 	r.tasks[id] = task
 	return nil
 }
+
+func (r *TasksRepository) SealTask(id int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, exists := r.tasks[id]
+	if !exists {
+		return fmt.Errorf("задача с идентификатором %d не найдена", id)
+	}
+
+	task.Sealed = true
+	r.tasks[id] = task
+	return nil
+}
+
+func (r *TasksRepository) UpdatePendingJobs(id int64, delta int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, exists := r.tasks[id]
+	if !exists {
+		return fmt.Errorf("задача с идентификатором %d не найдена", id)
+	}
+
+	task.PendingJobs += delta
+	r.tasks[id] = task
+	return nil
+}
+
+// StartArchiving атомарно переводит завершённую задачу в статус TaskArchiving.
+// Возвращает false, если задача ещё не завершена или сборка архива уже запущена
+func (r *TasksRepository) StartArchiving(id int64) (Task, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, exists := r.tasks[id]
+	if !exists {
+		return Task{}, false, fmt.Errorf("задача с идентификатором %d не найдена", id)
+	}
+	if task.Finalized || !task.IsFinished() {
+		return task, false, nil
+	}
+
+	task.Finalized = true
+	task.Status = TaskArchiving
+	task.FinishedAt = time.Now()
+	r.tasks[id] = task
+	return task, true, nil
+}
@@ -1,6 +1,11 @@
 package routes
 
 import (
+	"backend/internal/config"
+	"backend/internal/repository"
+	"backend/internal/service"
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -12,16 +17,24 @@ import (
 // downloadArchive скачивает архив по ID задачи
 //
 // @Summary      Скачать архив по ID задачи
-// @Description  Скачивает архив, связанный с задачей, по её ID
+// @Description  Скачивает архив, связанный с задачей, по её ID. По умолчанию отдаётся архив в формате задачи,
+// @Description  архив в другом формате собирается при первом запросе и кэшируется.
+// @Description  В режиме stream архив собирается на лету, Content-Length есть, если размер известен заранее (tar и zip без сжатия)
 // @Tags         archives
-// @Param        id   path      int64  true  "Task ID"
+// @Param        id      path   int64   true   "Task ID"
+// @Param        format  query  string  false  "Формат архива" Enums(zip, tar, tar.gz, tar.zst)
 // @Produce      application/zip
+// @Produce      application/x-tar
+// @Produce      application/gzip
+// @Produce      application/zstd
+// @Produce      application/octet-stream
 // @Success      200  {file}  archive.zip
-// @Failure      400  {string}  string  "Неверный ID задачи"
+// @Failure      400  {string}  string  "Неверный ID задачи или формат архива, например zip для задачи с ключом получателя age"
 // @Failure      404  {string}  string  "Архив не найден"
 // @Failure      500  {string}  string  "Ошибка открытия файла"
 // @Router       /api/archives/{id}/download [get]
-func (h *Handler) downloadArchive(log *slog.Logger) http.HandlerFunc {
+// @Router       /api/archives/{id}/download [head]
+func (h *Handler) downloadArchive(log *slog.Logger, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		idStr := chi.URLParam(r, "id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
@@ -31,14 +44,37 @@ func (h *Handler) downloadArchive(log *slog.Logger) http.HandlerFunc {
 			return
 		}
 
-		path, err := h.services.Tasks.GetArchivePath(id)
-		if err != nil {
+		format := r.URL.Query().Get("format")
+		if err := service.ValidateArchiveOptions(repository.ArchiveOptions{Format: format}); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		archive, err := h.services.Tasks.GetArchive(id, format, cfg)
+		if errors.Is(err, service.ErrArchiveNotFound) {
 			log.Error("Ошибка получения пути к архиву", slog.String("error", err.Error()))
 			http.Error(w, "Архив не найден", http.StatusNotFound)
 			return
 		}
+		if errors.Is(err, service.ErrArchiveFormat) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err != nil {
+			log.Error("Ошибка при создании архива", slog.Int64("task_id", id), slog.String("error", err.Error()))
+			http.Error(w, "Ошибка при создании архива", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", archive.FileName))
+		w.Header().Set("Content-Type", archive.ContentType)
+
+		if archive.Path == "" {
+			streamArchive(w, r, id, archive, log)
+			return
+		}
 
-		file, err := http.Dir("./").Open(path)
+		file, err := http.Dir("./").Open(archive.Path)
 		if err != nil {
 			log.Error("Ошибка открытия файла архива", slog.String("error", err.Error()))
 			http.Error(w, "Ошибка открытия файла", http.StatusInternalServerError)
@@ -46,8 +82,128 @@ func (h *Handler) downloadArchive(log *slog.Logger) http.HandlerFunc {
 		}
 		defer file.Close()
 
-		w.Header().Set("Content-Disposition", "attachment; filename=\"archive.zip\"")
-		w.Header().Set("Content-Type", "application/zip")
-		http.ServeContent(w, r, "archive.zip", time.Time{}, file)
+		http.ServeContent(w, r, archive.FileName, time.Time{}, file)
+	}
+}
+
+// downloadSignature отдаёт отсоединённую подпись архива
+//
+// @Summary      Скачать подпись архива
+// @Description  Отдаёт подпись ed25519 (64 байта) архива. Подписывается не сам архив, а его SHA-256:
+// @Description  сообщение для ed25519 - 32 байта дайджеста SHA-256 архива (то же значение, что в checksum без префикса sha256:).
+// @Description  Для проверки посчитайте SHA-256 скачанного архива и проверьте подпись этого дайджеста открытым ключом
+// @Description  из /.well-known/archiver-signing-key, например openssl dgst -sha256 -binary archive.zip | openssl pkeyutl -verify -pubin -inkey key.pem -rawin -sigfile archive.zip.sig.
+// @Description  Архив в другом формате в режиме file собирается при первом запросе подписи, потоковые архивы подписываются только в формате задачи
+// @Tags         archives
+// @Param        id      path   int64   true   "Task ID"
+// @Param        format  query  string  false  "Формат архива, по умолчанию формат задачи" Enums(zip, tar, tar.gz, tar.zst)
+// @Produce      application/octet-stream
+// @Success      200  {file}    archive.zip.sig
+// @Failure      400  {string}  string  "Неверный ID задачи или формат архива"
+// @Failure      404  {string}  string  "Архив не найден, не подписан или подпись архивов не настроена"
+// @Router       /api/archives/{id}/signature [get]
+func (h *Handler) downloadSignature(log *slog.Logger, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			log.Error("Неверный ID задачи", slog.String("error", err.Error()))
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		if err := service.ValidateArchiveOptions(repository.ArchiveOptions{Format: format}); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		signature, err := h.services.Tasks.GetSignature(id, format, cfg)
+		switch {
+		case errors.Is(err, service.ErrSigningDisabled), errors.Is(err, service.ErrSignatureNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		case errors.Is(err, service.ErrArchiveNotFound):
+			http.Error(w, "Архив не найден", http.StatusNotFound)
+			return
+		case errors.Is(err, service.ErrArchiveFormat):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		case err != nil:
+			log.Error("Ошибка получения подписи архива", slog.Int64("task_id", id), slog.String("error", err.Error()))
+			http.Error(w, "Ошибка получения подписи архива", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", signature.FileName))
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(signature.Data)
+	}
+}
+
+// signingKey отдаёт открытый ключ подписи архивов
+//
+// @Summary      Открытый ключ подписи архивов
+// @Description  Открытый ключ ed25519 в PEM, которым проверяются подписи /api/archives/{id}/signature.
+// @Description  Подпись ставится на 32 байта SHA-256 архива, а не на сам архив: проверяйте её по дайджесту
+// @Tags         archives
+// @Produce      application/x-pem-file
+// @Success      200  {string}  string  "Открытый ключ в PEM"
+// @Failure      404  {string}  string  "Подпись архивов не настроена"
+// @Router       /.well-known/archiver-signing-key [get]
+func (h *Handler) signingKey(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key, err := h.services.Tasks.SigningKey()
+		if errors.Is(err, service.ErrSigningDisabled) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Error("Ошибка получения ключа подписи", slog.String("error", err.Error()))
+			http.Error(w, "Ошибка получения ключа подписи", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/x-pem-file")
+		w.Write(key)
+	}
+}
+
+// streamArchive собирает архив прямо в ответ. Content-Length отправляется, если размер известен заранее
+func streamArchive(w http.ResponseWriter, r *http.Request, id int64, archive *service.Archive, log *slog.Logger) {
+	if archive.Size >= 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(archive.Size, 10))
+	}
+	if r.Method == http.MethodHead {
+		return
+	}
+
+	// большой архив пишется дольше HTTP_TIMEOUT, поэтому срок записи для этого ответа снимается
+	if err := http.NewResponseController(w).SetWriteDeadline(time.Time{}); err != nil {
+		log.Warn("Не удалось снять ограничение времени записи", slog.String("error", err.Error()))
+	}
+	if err := archive.Stream(w); err != nil {
+		log.Error("Ошибка при передаче архива", slog.Int64("task_id", id), slog.String("error", err.Error()))
+		// заголовки уже отправлены: обрываем соединение, чтобы клиент не принял обрезанный архив за целый
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// parseArchiveOptions читает параметры архива из формы создания задачи
+func parseArchiveOptions(r *http.Request) (repository.ArchiveOptions, error) {
+	archive := repository.ArchiveOptions{
+		Format:      r.FormValue("format"),
+		Compression: r.FormValue("compression"),
+		Mode:        r.FormValue("archive_mode"),
+		Layout:      r.FormValue("layout"),
+		Passphrase:  r.FormValue("passphrase"),
+		Recipient:   r.FormValue("recipient"),
+	}
+	if level := r.FormValue("compression_level"); level != "" {
+		var err error
+		if archive.CompressionLevel, err = strconv.Atoi(level); err != nil {
+			return archive, fmt.Errorf("compression_level должен быть числом")
+		}
 	}
+	return archive, service.ValidateArchiveOptions(archive)
 }
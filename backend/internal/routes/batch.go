@@ -0,0 +1,298 @@
+package routes
+
+import (
+	"backend/internal/config"
+	"backend/internal/repository"
+	"backend/internal/service"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+)
+
+const (
+	maxBatchBodySize  = 1 << 20
+	maxImportBodySize = 6 << 20
+)
+
+// LinkRequest - ссылка в JSON-запросе. Поля те же, что у формы add-link
+type LinkRequest struct {
+	URL     string   `json:"url"`
+	Mirrors []string `json:"mirrors,omitempty"`
+	Name    string   `json:"name,omitempty"`
+	// Folder - папка файла в архиве, важнее раскладки архива
+	Folder string `json:"folder,omitempty"`
+	// Checksum - ожидаемая контрольная сумма: sha256:…, sha512:… или md5:…
+	Checksum string `json:"checksum,omitempty"`
+	// Expand - url является шаблоном с диапазонами, перечислениями или датами
+	Expand bool `json:"expand,omitempty"`
+	// Preflight - проверить доступность, тип и размер файла до добавления
+	Preflight bool   `json:"preflight,omitempty"`
+	Mode      string `json:"mode,omitempty" enums:"file,extract,feed"`
+	repository.RequestOptions
+	Selector string `json:"selector,omitempty"`
+	Pattern  string `json:"pattern,omitempty"`
+	Depth    int    `json:"depth,omitempty"`
+	SameHost bool   `json:"same_host,omitempty"`
+	Since    string `json:"since,omitempty"`
+	Until    string `json:"until,omitempty"`
+}
+
+type CreateTaskRequest struct {
+	Links []LinkRequest `json:"links"`
+	// Options - параметры запроса по умолчанию для всех ссылок
+	Options repository.RequestOptions `json:"options"`
+	// Archive - параметры архива задачи
+	Archive repository.ArchiveOptions `json:"archive"`
+}
+
+type AppendLinksRequest struct {
+	Links   []LinkRequest             `json:"links"`
+	Options repository.RequestOptions `json:"options"`
+}
+
+type CreateTaskResponse struct {
+	Id      int64                `json:"id"`
+	Results []service.LinkResult `json:"results"`
+}
+
+type AppendLinksResponse struct {
+	Results []service.LinkResult `json:"results"`
+}
+
+// createTaskWithLinks godoc
+// @Summary      Создать задачу со списком ссылок
+// @Description  Создаёт задачу и сразу добавляет в неё ссылки. Для каждой ссылки возвращается, принята она или отклонена.
+// @Description  Задача закрывается для новых ссылок: архив собирается, когда обработаны все принятые файлы
+// @Tags         tasks
+// @Accept       json
+// @Produce      json
+// @Param        request  body      CreateTaskRequest   true  "Ссылки и параметры запроса по умолчанию"
+// @Success      201      {object}  CreateTaskResponse  "Задача создана"
+// @Failure      400      {string}  string "Некорректное тело запроса"
+// @Failure      500      {string}  string "Ошибка при создании задачи"
+// @Router       /api/tasks [post]
+func (h *Handler) createTaskWithLinks(log *slog.Logger, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req CreateTaskRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodySize)).Decode(&req); err != nil {
+			http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+			return
+		}
+		if len(req.Links) == 0 {
+			http.Error(w, "Список ссылок не может быть пустым", http.StatusBadRequest)
+			return
+		}
+
+		if err := service.ValidateArchiveOptions(req.Archive); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		links, err := toServiceLinks(req.Links, req.Options)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		id, results, err := h.services.Tasks.CreateTaskWithLinks(links, req.Archive, log, cfg)
+		if err != nil {
+			log.Error("Ошибка при создании задачи", slog.String("error", err.Error()))
+			http.Error(w, "Ошибка при создании задачи", http.StatusInternalServerError)
+			return
+		}
+
+		log.Info("Задача успешно создана", slog.Int64("task_id", id), slog.Int("links", len(links)))
+		writeJSON(w, http.StatusCreated, CreateTaskResponse{Id: id, Results: results}, log)
+	}
+}
+
+// appendLinks godoc
+// @Summary      Добавить несколько ссылок к задаче
+// @Description  Добавляет ссылки к задаче по её ID. Для каждой ссылки возвращается, принята она или отклонена
+// @Tags         tasks
+// @Accept       json
+// @Produce      json
+// @Param        id       path      int                  true  "ID задачи"
+// @Param        request  body      AppendLinksRequest   true  "Ссылки и параметры запроса по умолчанию"
+// @Success      200      {object}  AppendLinksResponse  "Результат по каждой ссылке"
+// @Failure      400      {string}  string "Неверный ID задачи или некорректное тело запроса"
+// @Failure      404      {string}  string "Задача не найдена"
+// @Router       /api/tasks/{id}/links [post]
+func (h *Handler) appendLinks(log *slog.Logger, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			log.Error("Неверный ID задачи", slog.String("error", err.Error()))
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
+			return
+		}
+
+		var req AppendLinksRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodySize)).Decode(&req); err != nil {
+			http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+			return
+		}
+		if len(req.Links) == 0 {
+			http.Error(w, "Список ссылок не может быть пустым", http.StatusBadRequest)
+			return
+		}
+
+		if _, err := h.services.Tasks.GetTask(id); err != nil {
+			http.Error(w, "Задача не найдена", http.StatusNotFound)
+			return
+		}
+
+		links, err := toServiceLinks(req.Links, req.Options)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		results := h.services.Tasks.AppendLinks(id, links, log, cfg)
+		log.Info("Ссылки добавлены к задаче", slog.Int64("task_id", id), slog.Int("links", len(links)))
+		writeJSON(w, http.StatusOK, AppendLinksResponse{Results: results}, log)
+	}
+}
+
+// toServiceLinks переводит ссылки из JSON в модель сервиса. Параметры запроса по умолчанию
+// дополняются параметрами конкретной ссылки
+func toServiceLinks(reqs []LinkRequest, defaults repository.RequestOptions) ([]service.Link, error) {
+	links := make([]service.Link, 0, len(reqs))
+	for i, req := range reqs {
+		since, err := parseDate(req.Since, false)
+		if err != nil {
+			return nil, fmt.Errorf("ссылка %d: since: %w", i+1, err)
+		}
+		until, err := parseDate(req.Until, true)
+		if err != nil {
+			return nil, fmt.Errorf("ссылка %d: until: %w", i+1, err)
+		}
+
+		links = append(links, service.Link{
+			URL:       req.URL,
+			Mirrors:   req.Mirrors,
+			Name:      req.Name,
+			Folder:    req.Folder,
+			Checksum:  req.Checksum,
+			Expand:    req.Expand,
+			Preflight: req.Preflight,
+			Options:   mergeOptions(defaults, req.RequestOptions),
+			Mode:      req.Mode,
+			Extract: service.ExtractOptions{
+				Selector: req.Selector,
+				Pattern:  req.Pattern,
+				Depth:    req.Depth,
+				SameHost: req.SameHost,
+			},
+			Feed: service.FeedOptions{Since: since, Until: until},
+		})
+	}
+	return links, nil
+}
+
+func mergeOptions(defaults, opts repository.RequestOptions) repository.RequestOptions {
+	merged := defaults
+	merged.Headers = mergeMaps(defaults.Headers, opts.Headers)
+	merged.Cookies = mergeMaps(defaults.Cookies, opts.Cookies)
+	if opts.Username != "" || opts.Password != "" {
+		merged.Username, merged.Password = opts.Username, opts.Password
+	}
+	if opts.BearerToken != "" {
+		merged.BearerToken = opts.BearerToken
+	}
+	if opts.UserAgent != "" {
+		merged.UserAgent = opts.UserAgent
+	}
+	if opts.Proxy != "" {
+		merged.Proxy = opts.Proxy
+	}
+	return merged
+}
+
+func mergeMaps(defaults, values map[string]string) map[string]string {
+	if len(defaults) == 0 && len(values) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(defaults)+len(values))
+	for k, v := range defaults {
+		merged[k] = v
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return merged
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any, log *slog.Logger) {
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		log.Error("Ошибка при сериализации ответа", slog.String("error", err.Error()))
+		http.Error(w, "Ошибка при сериализации ответа", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(bytes)
+}
+
+// importLinks godoc
+// @Summary      Импортировать список ссылок
+// @Description  Принимает файл со списком ссылок и добавляет каждую строку к задаче: текст (по ссылке в строке),
+// @Description  CSV с колонками url, filename, checksum или Metalink 4 (.meta4) с зеркалами и хэшами. Для каждой строки возвращается, принята она или отклонена
+// @Tags         tasks
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        id           path      int     true   "ID задачи"
+// @Param        file         formData  file    true   "Файл со списком ссылок"
+// @Param        format       formData  string  false  "Формат файла, по умолчанию определяется по расширению" Enums(txt, csv, meta4)
+// @Param        header       formData  []string false "Заголовок запроса в формате Name: value, для всех ссылок" collectionFormat(multi)
+// @Param        cookie       formData  []string false "Кука в формате name=value, для всех ссылок" collectionFormat(multi)
+// @Param        username     formData  string  false  "Логин для Basic-авторизации"
+// @Param        password     formData  string  false  "Пароль для Basic-авторизации"
+// @Param        bearer_token formData  string  false  "Bearer-токен"
+// @Param        user_agent   formData  string  false  "User-Agent запроса"
+// @Param        proxy        formData  string  false  "Прокси для всех ссылок (http, https, socks5)"
+// @Success      200  {object}  AppendLinksResponse  "Результат по каждой строке"
+// @Failure      400  {string}  string "Неверный ID задачи, отсутствует файл или файл не удалось разобрать"
+// @Router       /api/tasks/{id}/import [post]
+func (h *Handler) importLinks(log *slog.Logger, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		idStr := chi.URLParam(r, "id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			log.Error("Неверный ID задачи", slog.String("error", err.Error()))
+			http.Error(w, "Неверный ID задачи", http.StatusBadRequest)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxImportBodySize)
+		if err := r.ParseMultipartForm(maxImportBodySize); err != nil {
+			http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+			return
+		}
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, "Файл со списком ссылок не передан", http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+
+		opts, err := parseRequestOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		results, err := h.services.Tasks.ImportLinks(id, file, header.Filename, r.FormValue("format"), opts, log, cfg)
+		if err != nil {
+			log.Error("Ошибка при импорте списка ссылок", slog.Int64("task_id", id), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, http.StatusOK, AppendLinksResponse{Results: results}, log)
+	}
+}
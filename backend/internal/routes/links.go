@@ -0,0 +1,82 @@
+package routes
+
+import (
+	"backend/internal/config"
+	"backend/internal/repository"
+	"backend/internal/service"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+)
+
+const maxValidateLinks = 100
+
+type ValidateLinksRequest struct {
+	Links   []LinkRequest             `json:"links"`
+	Options repository.RequestOptions `json:"options"`
+}
+
+type ValidateLinksResponse struct {
+	Results []service.PreflightResult `json:"results"`
+}
+
+// testRewrite godoc
+// @Summary      Проверить правила переписывания ссылки
+// @Description  Показывает, во что превратится ссылка после применения правил из REWRITE_RULES_FILE, и какие заголовки добавит правило
+// @Tags         links
+// @Produce      json
+// @Param        link  formData  string  true  "Ссылка для проверки"
+// @Success      200   {object}  service.RewriteResult  "Результат применения правил"
+// @Failure      400   {string}  string "Пустая ссылка"
+// @Router       /api/links/rewrite [post]
+func (h *Handler) testRewrite(log *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		link := r.FormValue("link")
+		if link == "" {
+			http.Error(w, "Ссылка не может быть пустой", http.StatusBadRequest)
+			return
+		}
+		var result service.RewriteResult = h.services.Tasks.TestRewrite(link)
+		log.Info("Проверка правил переписывания", slog.String("rule", result.Rule))
+		writeJSON(w, http.StatusOK, result, log)
+	}
+}
+
+// validateLinks godoc
+// @Summary      Проверить ссылки без добавления в задачу
+// @Description  Для каждой ссылки отправляет HEAD (или GET первого байта, если HEAD не поддерживается) и проверяет доступность,
+// @Description  тип содержимого и размер. Если основная ссылка не прошла проверку, проверяются зеркала
+// @Tags         links
+// @Accept       json
+// @Produce      json
+// @Param        request  body      ValidateLinksRequest   true  "Ссылки и параметры запроса по умолчанию"
+// @Success      200      {object}  ValidateLinksResponse  "Результат по каждой ссылке"
+// @Failure      400      {string}  string "Некорректное тело запроса"
+// @Router       /api/links/validate [post]
+func (h *Handler) validateLinks(log *slog.Logger, cfg *config.Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req ValidateLinksRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBatchBodySize)).Decode(&req); err != nil {
+			http.Error(w, "Некорректное тело запроса", http.StatusBadRequest)
+			return
+		}
+		if len(req.Links) == 0 {
+			http.Error(w, "Список ссылок не может быть пустым", http.StatusBadRequest)
+			return
+		}
+		if len(req.Links) > maxValidateLinks {
+			http.Error(w, "Слишком много ссылок в одном запросе", http.StatusBadRequest)
+			return
+		}
+
+		links, err := toServiceLinks(req.Links, req.Options)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		results := h.services.Tasks.ValidateLinks(links, cfg)
+		log.Info("Ссылки проверены", slog.Int("links", len(links)))
+		writeJSON(w, http.StatusOK, ValidateLinksResponse{Results: results}, log)
+	}
+}
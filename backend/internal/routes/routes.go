@@ -21,16 +21,27 @@ func NewHandler(services *service.Service) *Handler {
 
 func (h *Handler) RegisterRoutes(router *chi.Mux, log *slog.Logger, cfg *config.Config) {
 	router.Get("/swagger/*", httpSwagger.WrapHandler)
+	router.Get("/.well-known/archiver-signing-key", h.signingKey(log))
 
 	router.Route("/api", func(r chi.Router) {
 		r.Route("/tasks", func(r chi.Router) {
-			r.Post("/create", h.createTask(log))
+			r.Post("/", h.createTaskWithLinks(log, cfg))
+			r.Post("/create", h.createTask(log, cfg))
 			r.Post("/{id}/add-link", h.addLink(log, cfg))
+			r.Post("/{id}/links", h.appendLinks(log, cfg))
+			r.Post("/{id}/import", h.importLinks(log, cfg))
 			r.Get("/{id}/status", h.getStatuses(log, cfg))
 		})
 
+		r.Route("/links", func(r chi.Router) {
+			r.Post("/rewrite", h.testRewrite(log))
+			r.Post("/validate", h.validateLinks(log, cfg))
+		})
+
 		r.Route("/archives", func(r chi.Router) {
-			r.Get("/{id}/download", h.downloadArchive(log))
+			r.Get("/{id}/download", h.downloadArchive(log, cfg))
+			r.Head("/{id}/download", h.downloadArchive(log, cfg))
+			r.Get("/{id}/signature", h.downloadSignature(log, cfg))
 		})
 	})
 }
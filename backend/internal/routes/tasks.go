@@ -3,11 +3,15 @@ package routes
 import (
 	"backend/internal/config"
 	"backend/internal/repository"
+	"backend/internal/service"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 )
@@ -18,12 +22,26 @@ type Task *repository.Task
 // @Summary      Создать новую задачу
 // @Description  Создает новую задачу и возвращает её ID
 // @Tags         tasks
+// @Param        format             formData  string  false  "Формат архива, по умолчанию ARCHIVE_FORMAT" Enums(zip, tar, tar.gz, tar.zst)
+// @Param        compression        formData  string  false  "Сжатие записей zip, по умолчанию ARCHIVE_COMPRESSION" Enums(auto, deflate, store)
+// @Param        compression_level  formData  int     false  "Уровень сжатия 1-9, по умолчанию ARCHIVE_COMPRESSION_LEVEL"
+// @Param        archive_mode       formData  string  false  "file - архив собирается на диске, stream - на лету при скачивании, по умолчанию ARCHIVE_MODE" Enums(file, stream)
+// @Param        layout             formData  string  false  "Раскладка файлов по папкам: flat - все в корне, host - по хосту ссылки, type - по типу содержимого, по умолчанию ARCHIVE_LAYOUT" Enums(flat, host, type)
+// @Param        passphrase         formData  string  false  "Пароль для шифрования архива: zip - AES-256 (WinZip AE-2), tar - age"
+// @Param        recipient          formData  string  false  "Открытый ключ age (age1...) для шифрования tar-архива"
 // @Success      201 {string} string "Задача успешно создана с ID: {id}"
+// @Failure      400 {string} string "Некорректные параметры архива"
 // @Failure      500 {string} string "Ошибка при создании задачи"
 // @Router       /api/tasks/create [post]
-func (h *Handler) createTask(log *slog.Logger) http.HandlerFunc {
+func (h *Handler) createTask(log *slog.Logger, cfg *config.Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		idx, err := h.services.Tasks.CreateTask()
+		archive, err := parseArchiveOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		idx, err := h.services.Tasks.CreateTask(archive, cfg)
 		if err != nil {
 			log.Error("Ошибка при создании задачи", slog.String("error", err.Error()))
 			http.Error(w, "Ошибка при создании задачи", http.StatusInternalServerError)
@@ -39,10 +57,33 @@ func (h *Handler) createTask(log *slog.Logger) http.HandlerFunc {
 // @Summary      Добавить ссылку к задаче
 // @Description  Добавляет ссылку к задаче по её ID
 // @Tags         tasks
-// @Param        id   path      int    true  "ID задачи"
-// @Param        link formData  string true  "Ссылка для добавления"
-// @Success      200  {string}  string "Ссылка успешно добавлена к задаче"
+// @Param        id           path      int      true   "ID задачи"
+// @Param        link         formData  string   true   "Ссылка для добавления"
+// @Param        mirror       formData  []string false  "Зеркало того же файла, пробуются по порядку, если основная ссылка недоступна" collectionFormat(multi)
+// @Param        expand       formData  bool     false  "Ссылка - шаблон: [001-120] - диапазон, {a,b,c} - перечисление, {date:2024-01-01..2024-01-31|20060102} - даты"
+// @Param        dry_run      formData  bool     false  "Только показать, во что раскроется шаблон, не добавляя ссылки"
+// @Param        checksum     formData  string   false  "Ожидаемая контрольная сумма файла: sha256:…, sha512:… или md5:…"
+// @Param        folder       formData  string   false  "Папка файла в архиве, важнее раскладки архива"
+// @Param        preflight    formData  bool     false  "Проверить доступность, тип и размер файла до добавления"
+// @Param        mode         formData  string   false  "Режим ссылки: file (по умолчанию), extract - разобрать HTML-страницу, feed - добавить вложения RSS/Atom-ленты" Enums(file, extract, feed)
+// @Param        selector     formData  string   false  "extract: CSS-селектор элементов со ссылками на файлы"
+// @Param        pattern      formData  string   false  "extract: регулярное выражение для отбора ссылок"
+// @Param        depth        formData  int      false  "extract: глубина обхода связанных страниц (0-3)"
+// @Param        same_host    formData  bool     false  "extract: не выходить за пределы хоста страницы"
+// @Param        since        formData  string   false  "feed: вложения, опубликованные не раньше даты (2006-01-02 или RFC 3339)"
+// @Param        until        formData  string   false  "feed: вложения, опубликованные не позже даты (2006-01-02 или RFC 3339)"
+// @Param        header       formData  []string false  "Заголовок запроса в формате Name: value" collectionFormat(multi)
+// @Param        cookie       formData  []string false  "Кука в формате name=value" collectionFormat(multi)
+// @Param        username     formData  string   false  "Логин для Basic-авторизации"
+// @Param        password     formData  string   false  "Пароль для Basic-авторизации"
+// @Param        bearer_token formData  string   false  "Bearer-токен"
+// @Param        user_agent   formData  string   false  "User-Agent запроса"
+// @Param        proxy        formData  string   false  "Прокси для этой ссылки (http, https, socks5)"
+// @Success      200  {object}  service.LinkResult "Ссылка добавлена или объединена с уже добавленной (merged, duplicate_of), при preflight - с типом, размером и предупреждениями проверки"
+// @Success      200  {object}  service.TemplatePreview "Результат пробного раскрытия шаблона (dry_run)"
 // @Failure      400  {string}  string "Неверный ID задачи или пустая ссылка"
+// @Failure      409  {string}  string "Ссылка уже добавлена в задачу (DEDUP_MODE=reject)"
+// @Failure      422  {string}  string "Ссылка не прошла предварительную проверку"
 // @Failure      500  {string}  string "Ошибка при добавлении ссылки к задаче"
 // @Router       /api/tasks/{id}/add-link [post]
 func (h *Handler) addLink(log *slog.Logger, cfg *config.Config) http.HandlerFunc {
@@ -62,17 +103,188 @@ func (h *Handler) addLink(log *slog.Logger, cfg *config.Config) http.HandlerFunc
 			return
 		}
 
-		err = h.services.Tasks.AppendLink(id, link, log, cfg)
+		expand, err := parseBool(r.FormValue("expand"), "expand")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		dryRun, err := parseBool(r.FormValue("dry_run"), "dry_run")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		preflight, err := parseBool(r.FormValue("preflight"), "preflight")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if dryRun {
+			if !expand {
+				http.Error(w, "dry_run поддерживается только для шаблонов (expand=true)", http.StatusBadRequest)
+				return
+			}
+			preview, err := h.services.Tasks.PreviewTemplate(id, link, cfg)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, http.StatusOK, preview, log)
+			return
+		}
+
+		opts, err := parseRequestOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		extract, err := parseExtractOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		feed, err := parseFeedOptions(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		result, err := h.services.Tasks.AppendLink(id, service.Link{
+			URL:       link,
+			Mirrors:   r.Form["mirror"],
+			Options:   opts,
+			Checksum:  r.FormValue("checksum"),
+			Folder:    r.FormValue("folder"),
+			Expand:    expand,
+			Preflight: preflight,
+			Mode:      r.FormValue("mode"),
+			Extract:   extract,
+			Feed:      feed,
+		}, log, cfg)
+		var preflightErr *service.PreflightError
+		if errors.As(err, &preflightErr) {
+			log.Info("Ссылка не прошла предварительную проверку", slog.Int64("task_id", id), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if errors.Is(err, service.ErrDuplicateLink) {
+			log.Info("Повторная ссылка отклонена", slog.Int64("task_id", id), slog.String("error", err.Error()))
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
 		if err != nil {
 			log.Error("Ошибка при добавлении ссылки к задаче", slog.String("error", err.Error()))
 			http.Error(w, "Ошибка при добавлении ссылки к задаче", http.StatusInternalServerError)
 			return
 		}
 
-		log.Info("Ссылка успешно добавлена к задаче", slog.Int64("task_id", id), slog.String("link", link))
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Ссылка успешно добавлена к задаче"))
+		if result.Merged {
+			log.Info("Ссылка объединена с файлом задачи", slog.Int64("task_id", id), slog.String("link", service.RedactLink(link)), slog.Int("file", *result.DuplicateOf))
+		} else {
+			log.Info("Ссылка успешно добавлена к задаче", slog.Int64("task_id", id), slog.String("link", service.RedactLink(link)))
+		}
+		writeJSON(w, http.StatusOK, result, log)
+	}
+}
+
+// parseRequestOptions собирает параметры запроса из полей формы add-link
+func parseRequestOptions(r *http.Request) (repository.RequestOptions, error) {
+	opts := repository.RequestOptions{
+		Username:    r.FormValue("username"),
+		Password:    r.FormValue("password"),
+		BearerToken: r.FormValue("bearer_token"),
+		UserAgent:   r.FormValue("user_agent"),
+		Proxy:       r.FormValue("proxy"),
+	}
+
+	for _, header := range r.Form["header"] {
+		name, value, ok := strings.Cut(header, ":")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return opts, fmt.Errorf("заголовок должен иметь формат Name: value")
+		}
+		if opts.Headers == nil {
+			opts.Headers = make(map[string]string)
+		}
+		opts.Headers[name] = strings.TrimSpace(value)
+	}
+
+	for _, cookie := range r.Form["cookie"] {
+		name, value, ok := strings.Cut(cookie, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return opts, fmt.Errorf("кука должна иметь формат name=value")
+		}
+		if opts.Cookies == nil {
+			opts.Cookies = make(map[string]string)
+		}
+		opts.Cookies[name] = strings.TrimSpace(value)
+	}
+
+	return opts, nil
+}
+
+func parseExtractOptions(r *http.Request) (service.ExtractOptions, error) {
+	opts := service.ExtractOptions{
+		Selector: r.FormValue("selector"),
+		Pattern:  r.FormValue("pattern"),
+	}
+	if depth := r.FormValue("depth"); depth != "" {
+		n, err := strconv.Atoi(depth)
+		if err != nil {
+			return opts, fmt.Errorf("глубина обхода должна быть числом")
+		}
+		opts.Depth = n
 	}
+	sameHost, err := parseBool(r.FormValue("same_host"), "same_host")
+	if err != nil {
+		return opts, err
+	}
+	opts.SameHost = sameHost
+	return opts, nil
+}
+
+func parseBool(value, field string) (bool, error) {
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s должен быть true или false", field)
+	}
+	return b, nil
+}
+
+func parseFeedOptions(r *http.Request) (service.FeedOptions, error) {
+	var opts service.FeedOptions
+	var err error
+	if opts.Since, err = parseDate(r.FormValue("since"), false); err != nil {
+		return opts, fmt.Errorf("since: %w", err)
+	}
+	if opts.Until, err = parseDate(r.FormValue("until"), true); err != nil {
+		return opts, fmt.Errorf("until: %w", err)
+	}
+	return opts, nil
+}
+
+// parseDate принимает дату в формате 2006-01-02 или RFC 3339. Для конца периода
+// дата без времени означает конец дня
+func parseDate(value string, endOfDay bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("дата должна быть в формате 2006-01-02 или RFC 3339")
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
 }
 
 type GetStatusesResponse struct {
@@ -82,7 +294,8 @@ type GetStatusesResponse struct {
 
 // getStatuses godoc
 // @Summary      Получить статусы задачи
-// @Description  Возвращает статусы задачи по её ID. В случае, когда ни один файл не удалось скачать, архив не будет возвращён.
+// @Description  Возвращает статусы задачи по её ID. Запрос ничего не меняет: архив собирается в фоне, когда обработаны все файлы,
+// @Description  на это время задача получает статус "Архивируется". В случае, когда ни один файл не удалось скачать, архив не будет возвращён.
 // @Description  Если задача завершена успешно/удалось установить хоть один файл на момент завершения, возвращает ссылку на скачивание архива
 // @Tags         tasks
 // @Param        id   path      int    true  "ID задачи"
@@ -110,15 +323,8 @@ func (h *Handler) getStatuses(log *slog.Logger, cfg *config.Config) http.Handler
 		log.Info("Статусы задачи успешно получены", slog.Int64("task_id", id))
 
 		var link string
-
-		if len(task.Links)+len(task.Errors) >= 3 && len(task.LoadedFilesLinks) > 0 && (task.Status == repository.TaskFailed || task.Status == repository.TaskCompleted) {
+		if task.ArchiveReady() {
 			link = fmt.Sprintf("http://localhost%s/api/archives/%d/download", cfg.HTTPServer.Address, id)
-			_, err := h.services.Tasks.MakeArchive(*task)
-			if err != nil {
-				log.Error("Ошибка при создании архива", slog.String("error", err.Error()), slog.Int64("task_id", id))
-				http.Error(w, "Ошибка при создании архива", http.StatusInternalServerError)
-				return
-			}
 		}
 
 		w.WriteHeader(http.StatusOK)
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"archive/zip"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+	"time"
+	"unicode/utf8"
+)
+
+// Шифрование записей zip по спецификации WinZip AE-2: AES-256 в режиме CTR,
+// ключ из пароля через PBKDF2-HMAC-SHA1, целостность - HMAC-SHA1 зашифрованных данных
+const (
+	aesMethod      = 99
+	aesExtraID     = 0x9901
+	aesVersion     = 2 // AE-2: CRC не записывается, его заменяет HMAC
+	aesStrength256 = 3
+	aesKeySize     = 32
+	aesSaltSize    = 16
+	aesVerifySize  = 2
+	aesMACSize     = 10
+	aesIterations  = 1000
+
+	zipFlagEncrypted      = 0x1
+	zipFlagDataDescriptor = 0x8
+	zipFlagUTF8           = 0x800
+)
+
+// aesEntryWriter сжимает (если нужно) и шифрует содержимое записи. После Close размеры
+// записываются в заголовок записи zip и в header - сведения о файле для отчёта
+type aesEntryWriter struct {
+	header  *zip.FileHeader
+	raw     *zip.FileHeader
+	out     io.Writer
+	comp    io.WriteCloser
+	ctr     *aesCTR
+	mac     hash.Hash
+	written int64
+	size    int64
+}
+
+// createAESEntry добавляет в zip зашифрованную запись. header.Method - настоящий метод сжатия
+func createAESEntry(zw *zip.Writer, header *zip.FileHeader, passphrase string, level int) (io.WriteCloser, error) {
+	salt := make([]byte, aesSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("ошибка при генерации соли: %w", err)
+	}
+	keys, err := pbkdf2.Key(sha1.New, passphrase, salt, aesIterations, 2*aesKeySize+aesVerifySize)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при получении ключа: %w", err)
+	}
+	block, err := aes.NewCipher(keys[:aesKeySize])
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при создании шифра: %w", err)
+	}
+
+	extra := make([]byte, 11)
+	binary.LittleEndian.PutUint16(extra[0:], aesExtraID)
+	binary.LittleEndian.PutUint16(extra[2:], 7)
+	binary.LittleEndian.PutUint16(extra[4:], aesVersion)
+	copy(extra[6:], "AE")
+	extra[8] = aesStrength256
+	binary.LittleEndian.PutUint16(extra[9:], header.Method)
+
+	raw := &zip.FileHeader{
+		Name:           header.Name,
+		Comment:        header.Comment,
+		Method:         aesMethod,
+		Flags:          zipFlagEncrypted | zipFlagDataDescriptor,
+		CreatorVersion: header.CreatorVersion&0xff00 | 51,
+		ReaderVersion:  51,
+		ExternalAttrs:  header.ExternalAttrs,
+		Extra:          append(extra, header.Extra...),
+	}
+	if !header.Modified.IsZero() {
+		raw.ModifiedDate, raw.ModifiedTime = msDosTime(header.Modified)
+	}
+	if !utf8Compatible(header.Name) || !utf8Compatible(header.Comment) {
+		raw.Flags |= zipFlagUTF8
+	}
+	out, err := zw.CreateRaw(raw)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := out.Write(append(salt, keys[2*aesKeySize:]...)); err != nil {
+		return nil, err
+	}
+
+	w := &aesEntryWriter{
+		header: header,
+		raw:    raw,
+		out:    out,
+		ctr:    &aesCTR{block: block, pos: aes.BlockSize},
+		mac:    hmac.New(sha1.New, keys[aesKeySize:2*aesKeySize]),
+	}
+	if header.Method == zip.Deflate {
+		if w.comp, err = flate.NewWriter(encryptWriter{w}, level); err != nil {
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+func (w *aesEntryWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	if w.comp != nil {
+		return w.comp.Write(p)
+	}
+	return encryptWriter{w}.Write(p)
+}
+
+func (w *aesEntryWriter) Close() error {
+	if w.comp != nil {
+		if err := w.comp.Close(); err != nil {
+			return err
+		}
+	}
+	if _, err := w.out.Write(w.mac.Sum(nil)[:aesMACSize]); err != nil {
+		return err
+	}
+
+	compressed := uint64(aesSaltSize+aesVerifySize+aesMACSize) + uint64(w.written)
+	for _, h := range []*zip.FileHeader{w.raw, w.header} {
+		h.CompressedSize64, h.UncompressedSize64 = compressed, uint64(w.size)
+		h.CompressedSize = uint32(min(compressed, 1<<32-1))
+		h.UncompressedSize = uint32(min(uint64(w.size), 1<<32-1))
+	}
+	return nil
+}
+
+// encryptWriter шифрует данные записи и считает HMAC по зашифрованному тексту
+type encryptWriter struct {
+	w *aesEntryWriter
+}
+
+func (e encryptWriter) Write(p []byte) (int, error) {
+	buf := make([]byte, len(p))
+	e.w.ctr.XORKeyStream(buf, p)
+	e.w.mac.Write(buf)
+	n, err := e.w.out.Write(buf)
+	e.w.written += int64(n)
+	return len(p), err
+}
+
+// aesCTR - CTR с little-endian счётчиком, начинающимся с 1, как в WinZip.
+// cipher.NewCTR увеличивает счётчик как big-endian и здесь не подходит
+type aesCTR struct {
+	block   cipher.Block
+	counter [aes.BlockSize]byte
+	stream  [aes.BlockSize]byte
+	pos     int
+}
+
+func (c *aesCTR) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if c.pos == aes.BlockSize {
+			for j := range c.counter {
+				c.counter[j]++
+				if c.counter[j] != 0 {
+					break
+				}
+			}
+			c.block.Encrypt(c.stream[:], c.counter[:])
+			c.pos = 0
+		}
+		dst[i] = src[i] ^ c.stream[c.pos]
+		c.pos++
+	}
+}
+
+// msDosTime переводит время в дату и время MS-DOS для заголовка zip. CreateRaw, в отличие
+// от CreateHeader, не переводит Modified сам
+func msDosTime(t time.Time) (date, clock uint16) {
+	date = uint16(t.Day() + int(t.Month())<<5 + (t.Year()-1980)<<9)
+	clock = uint16(t.Second()/2 + t.Minute()<<5 + t.Hour()<<11)
+	return date, clock
+}
+
+// utf8Compatible - строка в ASCII, флаг UTF-8 для неё не нужен
+func utf8Compatible(s string) bool {
+	for _, r := range s {
+		if r >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,199 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/flate"
+	"crypto/aes"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decryptAE2 независимо от aeszip.go читает запись, зашифрованную по спецификации WinZip AE-2:
+// проверяет extra-поле 0x9901, проверочное значение пароля и HMAC, расшифровывает AES-256-CTR
+// с 128-битным счётчиком little-endian, начиная с 1, и распаковывает данные
+func decryptAE2(f *zip.File, passphrase string) ([]byte, error) {
+	if f.Method != aesMethod || f.Flags&zipFlagEncrypted == 0 {
+		return nil, fmt.Errorf("метод %d, флаги %#x: запись не зашифрована AES", f.Method, f.Flags)
+	}
+	method := -1
+	for extra := f.Extra; len(extra) >= 4; {
+		id, size := binary.LittleEndian.Uint16(extra), int(binary.LittleEndian.Uint16(extra[2:]))
+		if len(extra) < 4+size {
+			break
+		}
+		if body := extra[4 : 4+size]; id == 0x9901 && size == 7 {
+			if binary.LittleEndian.Uint16(body) != 2 || string(body[2:4]) != "AE" || body[4] != 3 {
+				return nil, fmt.Errorf("extra 0x9901 не AE-2 AES-256: %x", body)
+			}
+			method = int(binary.LittleEndian.Uint16(body[5:]))
+		}
+		extra = extra[4+size:]
+	}
+	if method < 0 {
+		return nil, fmt.Errorf("нет extra-поля AES")
+	}
+	if f.CRC32 != 0 {
+		return nil, fmt.Errorf("в AE-2 CRC не записывается, получено %#x", f.CRC32)
+	}
+
+	r, err := f.OpenRaw()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < 16+2+10 {
+		return nil, fmt.Errorf("запись слишком короткая: %d байт", len(raw))
+	}
+	salt, verifier, data, mac := raw[:16], raw[16:18], raw[18:len(raw)-10], raw[len(raw)-10:]
+
+	keys, err := pbkdf2.Key(sha1.New, passphrase, salt, 1000, 66)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(keys[64:], verifier) {
+		return nil, fmt.Errorf("неверный пароль")
+	}
+	h := hmac.New(sha1.New, keys[32:64])
+	h.Write(data)
+	if !hmac.Equal(h.Sum(nil)[:10], mac) {
+		return nil, fmt.Errorf("HMAC не совпадает")
+	}
+
+	block, err := aes.NewCipher(keys[:32])
+	if err != nil {
+		return nil, err
+	}
+	plain := make([]byte, len(data))
+	var counter, stream [16]byte
+	for i := 0; i < len(data); i += 16 {
+		binary.LittleEndian.PutUint64(counter[:], uint64(i/16+1))
+		block.Encrypt(stream[:], counter[:])
+		for j := i; j < min(i+16, len(data)); j++ {
+			plain[j] = data[j] ^ stream[j-i]
+		}
+	}
+
+	switch uint16(method) {
+	case zip.Store:
+		return plain, nil
+	case zip.Deflate:
+		return io.ReadAll(flate.NewReader(bytes.NewReader(plain)))
+	default:
+		return nil, fmt.Errorf("неизвестный метод сжатия %d", method)
+	}
+}
+
+func TestAESZipRoundTrip(t *testing.T) {
+	const passphrase = "correct horse battery staple"
+	modified := time.Date(2021, 3, 4, 5, 6, 8, 0, time.UTC)
+	files := []struct {
+		name, content string
+		stored        bool
+	}{
+		{"text.txt", strings.Repeat("сжимаемый текст ", 1000), false},
+		{"отчёт.pdf", "%PDF-1.4 короткий", true},
+		{"empty.txt", "", false},
+		// 17 байт: последний блок счётчика неполный
+		{"odd.bin", "0123456789abcdefg", true},
+	}
+
+	var buf bytes.Buffer
+	archive, err := newArchiveWriter(&buf, ArchiveZip, compressionPolicy{mode: CompressionDeflate, level: 6}, &archiveEncryption{passphrase: passphrase})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range files {
+		src := entrySource{
+			name:    f.name,
+			comment: "https://example.com/" + f.name,
+			size:    int64(len(f.content)),
+			modTime: modified,
+			content: strings.NewReader(f.content),
+			data:    strings.NewReader(f.content),
+			stored:  f.stored,
+		}
+		if err := archive.Add(src); err != nil {
+			t.Fatalf("Add(%s): %v", f.name, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("архив не читается: %v", err)
+	}
+	if len(zr.File) != len(files) {
+		t.Fatalf("%d записей, ожидалось %d", len(zr.File), len(files))
+	}
+	for i, f := range zr.File {
+		want := files[i]
+		if f.Name != want.name || f.Comment != "https://example.com/"+want.name {
+			t.Errorf("запись %d: имя %q, комментарий %q", i, f.Name, f.Comment)
+		}
+		if !f.Modified.Equal(modified) {
+			t.Errorf("%s: время %v, ожидалось %v", f.Name, f.Modified, modified)
+		}
+		if f.UncompressedSize64 != uint64(len(want.content)) {
+			t.Errorf("%s: размер %d, ожидалось %d", f.Name, f.UncompressedSize64, len(want.content))
+		}
+		got, err := decryptAE2(f, passphrase)
+		if err != nil {
+			t.Errorf("%s: %v", f.Name, err)
+			continue
+		}
+		if string(got) != want.content {
+			t.Errorf("%s: расшифровано %d байт, содержимое не совпадает", f.Name, len(got))
+		}
+		if _, err := decryptAE2(f, "wrong"); err == nil {
+			t.Errorf("%s: запись открылась неверным паролем", f.Name)
+		}
+	}
+
+	entries := archive.Entries()
+	if entries[0].Method != CompressionDeflate || entries[0].CompressedSize >= entries[0].Size {
+		t.Errorf("текст не сжат: %+v", entries[0])
+	}
+	if entries[1].Method != CompressionStore {
+		t.Errorf("запись без сжатия: %+v", entries[1])
+	}
+}
+
+func TestAESZipTamperedData(t *testing.T) {
+	var buf bytes.Buffer
+	archive, err := newArchiveWriter(&buf, ArchiveZip, compressionPolicy{mode: CompressionStore}, &archiveEncryption{passphrase: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := "payload that will be modified"
+	if err := archive.Add(entrySource{name: "a.txt", size: int64(len(content)), content: strings.NewReader(content), data: strings.NewReader(content)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	data := buf.Bytes()
+	// зашифрованные данные идут после локального заголовка, соли и проверочного значения
+	offset := 30 + len("a.txt") + 11 + 16 + 2
+	data[offset+3] ^= 0xff
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := decryptAE2(zr.File[0], "secret"); err == nil || !strings.Contains(err.Error(), "HMAC") {
+		t.Errorf("изменённые данные не обнаружены: %v", err)
+	}
+}
@@ -0,0 +1,513 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"backend/internal/config"
+	"backend/internal/repository"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	ArchiveZip    = "zip"
+	ArchiveTar    = "tar"
+	ArchiveTarGz  = "tar.gz"
+	ArchiveTarZst = "tar.zst"
+
+	ArchiveModeFile   = "file"
+	ArchiveModeStream = "stream"
+
+	archivesDir = "./backend/archives"
+)
+
+// archiveEpoch - время изменения отчёта и файлов, для которых сервер не сообщил Last-Modified.
+// Вместе с порядком ссылок и одинаковыми атрибутами записей оно делает архив воспроизводимым:
+// одни и те же файлы дают побайтно одинаковый архив. 1980-01-01 - наименьшая дата, которую можно записать в zip
+var archiveEpoch = time.Date(1980, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// entryMode - права всех файлов в архиве
+const entryMode = 0o644
+
+// ErrArchiveNotFound - архив задачи ещё не собран или собрать его не удалось
+var ErrArchiveNotFound = errors.New("архив не найден")
+
+// ErrArchiveFormat - архив задачи нельзя получить в запрошенном формате
+var ErrArchiveFormat = errors.New("формат архива недоступен")
+
+// archiveFormat - расширение файла и Content-Type архива
+type archiveFormat struct {
+	Ext         string
+	ContentType string
+}
+
+var archiveFormats = map[string]archiveFormat{
+	ArchiveZip:    {Ext: ".zip", ContentType: "application/zip"},
+	ArchiveTar:    {Ext: ".tar", ContentType: "application/x-tar"},
+	ArchiveTarGz:  {Ext: ".tar.gz", ContentType: "application/gzip"},
+	ArchiveTarZst: {Ext: ".tar.zst", ContentType: "application/zstd"},
+}
+
+// fileFormat возвращает расширение и Content-Type архива с учётом шифрования:
+// tar, зашифрованный age, получает расширение .age
+func fileFormat(format string, encrypted bool) archiveFormat {
+	f := archiveFormats[format]
+	if encrypted && format != ArchiveZip {
+		f = archiveFormat{Ext: f.Ext + encryptedExt, ContentType: "application/octet-stream"}
+	}
+	return f
+}
+
+// ValidateArchiveOptions проверяет параметры архива из запроса. Пустые значения заменяются значениями по умолчанию
+func ValidateArchiveOptions(archive repository.ArchiveOptions) error {
+	if _, ok := archiveFormats[archive.Format]; archive.Format != "" && !ok {
+		return fmt.Errorf("неизвестный формат архива: %s, поддерживаются zip, tar, tar.gz, tar.zst", archive.Format)
+	}
+	switch archive.Compression {
+	case "", CompressionAuto, CompressionDeflate, CompressionStore:
+	default:
+		return fmt.Errorf("неизвестный режим сжатия: %s, поддерживаются auto, deflate, store", archive.Compression)
+	}
+	if archive.CompressionLevel < 0 || archive.CompressionLevel > 9 {
+		return fmt.Errorf("уровень сжатия должен быть от 1 до 9")
+	}
+	switch archive.Mode {
+	case "", ArchiveModeFile, ArchiveModeStream:
+	default:
+		return fmt.Errorf("неизвестный режим архива: %s, поддерживаются file, stream", archive.Mode)
+	}
+	switch archive.Layout {
+	case "", LayoutFlat, LayoutHost, LayoutType:
+	default:
+		return fmt.Errorf("неизвестная раскладка архива: %s, поддерживаются flat, host, type", archive.Layout)
+	}
+	return validateEncryption(archive)
+}
+
+// normalizeArchiveOptions проверяет параметры архива и подставляет значения по умолчанию из конфига
+func normalizeArchiveOptions(archive repository.ArchiveOptions, cfg *config.Config) (repository.ArchiveOptions, error) {
+	archive.Format = strings.ToLower(strings.TrimSpace(archive.Format))
+	if archive.Format == "" {
+		archive.Format = strings.ToLower(cfg.Archives.Format)
+	}
+	if archive.Compression == "" {
+		archive.Compression = strings.ToLower(cfg.Archives.Compression)
+	}
+	if archive.CompressionLevel == 0 {
+		archive.CompressionLevel = cfg.Archives.CompressionLevel
+	}
+	if archive.Mode == "" {
+		archive.Mode = strings.ToLower(cfg.Archives.Mode)
+	}
+	if archive.Layout == "" {
+		archive.Layout = strings.ToLower(cfg.Archives.Layout)
+	}
+	if err := ValidateArchiveOptions(archive); err != nil {
+		return archive, err
+	}
+	return archive, nil
+}
+
+// entrySource - файл, добавляемый в архив. content нужен для пробы сжимаемости, data - содержимое
+// для записи: то же самое или, когда считается только размер архива, нули той же длины.
+// stored - положить в zip без сжатия, не спрашивая политику, comment - ссылка, с которой скачан файл
+type entrySource struct {
+	name        string
+	comment     string
+	contentType string
+	size        int64
+	modTime     time.Time
+	content     io.ReaderAt
+	data        io.Reader
+	stored      bool
+}
+
+// archiveWriter - запись файлов в архив конкретного формата. Entries доступны после Close
+type archiveWriter interface {
+	Add(src entrySource) error
+	Close() error
+	Entries() []repository.ArchiveEntry
+}
+
+// newArchiveWriter создаёт запись архива. Если задан enc, записи zip шифруются AES-256,
+// а поток tar - целиком через age
+func newArchiveWriter(w io.Writer, format string, policy compressionPolicy, enc *archiveEncryption) (archiveWriter, error) {
+	if format == ArchiveZip {
+		zw := zip.NewWriter(w)
+		zw.RegisterCompressor(zip.Deflate, func(out io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(out, policy.level)
+		})
+		archive := &zipArchive{w: zw, policy: policy}
+		if enc != nil {
+			if enc.passphrase == "" {
+				return nil, errRecipientZip
+			}
+			archive.passphrase = enc.passphrase
+		}
+		return archive, nil
+	}
+
+	archive := &tarArchive{}
+	if enc != nil {
+		encrypted, err := enc.ageWriter(w)
+		if err != nil {
+			return nil, err
+		}
+		w = encrypted
+		archive.closers = append(archive.closers, encrypted)
+	}
+	switch format {
+	case ArchiveTar:
+	case ArchiveTarGz:
+		gz, err := gzip.NewWriterLevel(w, policy.level)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при создании сжатия gzip: %w", err)
+		}
+		w = gz
+		archive.closers = append(archive.closers, gz)
+	case ArchiveTarZst:
+		zw, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(policy.level)))
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при создании сжатия zstd: %w", err)
+		}
+		w = zw
+		archive.closers = append(archive.closers, zw)
+	default:
+		return nil, fmt.Errorf("неизвестный формат архива: %s", format)
+	}
+	archive.w = tar.NewWriter(w)
+	return archive, nil
+}
+
+// zipArchive выбирает метод сжатия каждой записи по политике. Размеры после сжатия
+// zip.Writer дописывает в заголовки, когда запись закрыта
+type zipArchive struct {
+	w          *zip.Writer
+	policy     compressionPolicy
+	passphrase string
+	headers    []*zip.FileHeader
+}
+
+func (a *zipArchive) Add(src entrySource) error {
+	store := src.stored
+	if !store {
+		var err error
+		if store, err = a.policy.Store(src.content, src.name, src.contentType); err != nil {
+			return err
+		}
+	}
+	// флаг UTF-8 для имён и комментариев не из ASCII zip.Writer ставит сам, для AES - createAESEntry
+	header := &zip.FileHeader{Name: src.name, Comment: src.comment, Method: zip.Deflate, Modified: src.modTime}
+	header.SetMode(entryMode)
+	if store {
+		header.Method = zip.Store
+	}
+	a.headers = append(a.headers, header)
+	if a.passphrase != "" {
+		w, err := createAESEntry(a.w, header, a.passphrase, a.policy.level)
+		if err != nil {
+			return fmt.Errorf("ошибка при создании файла в архиве: %w", err)
+		}
+		if _, err := io.Copy(w, src.data); err != nil {
+			return fmt.Errorf("ошибка при копировании файла в архив: %w", err)
+		}
+		return w.Close()
+	}
+
+	w, err := a.w.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("ошибка при создании файла в архиве: %w", err)
+	}
+	if _, err := io.Copy(w, src.data); err != nil {
+		return fmt.Errorf("ошибка при копировании файла в архив: %w", err)
+	}
+	return nil
+}
+
+func (a *zipArchive) Close() error {
+	return a.w.Close()
+}
+
+func (a *zipArchive) Entries() []repository.ArchiveEntry {
+	entries := make([]repository.ArchiveEntry, len(a.headers))
+	for i, header := range a.headers {
+		entries[i] = repository.ArchiveEntry{
+			Name:           header.Name,
+			Size:           int64(header.UncompressedSize64),
+			Method:         CompressionDeflate,
+			CompressedSize: int64(header.CompressedSize64),
+			Ratio:          compressionRatio(int64(header.CompressedSize64), int64(header.UncompressedSize64)),
+		}
+		if header.Method == zip.Store {
+			entries[i].Method = CompressionStore
+		}
+	}
+	return entries
+}
+
+// tarArchive - tar без сжатия или поверх gzip/zstd и age. closers закрываются после tar, начиная с последнего
+type tarArchive struct {
+	w       *tar.Writer
+	closers []io.Closer
+	entries []repository.ArchiveEntry
+}
+
+func (a *tarArchive) Add(src entrySource) error {
+	header := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     src.name,
+		Size:     src.size,
+		Mode:     entryMode,
+		ModTime:  src.modTime,
+	}
+	if src.comment != "" {
+		header.PAXRecords = map[string]string{"comment": src.comment}
+	}
+	if err := a.w.WriteHeader(header); err != nil {
+		return fmt.Errorf("ошибка при создании файла в архиве: %w", err)
+	}
+	if _, err := io.Copy(a.w, src.data); err != nil {
+		return fmt.Errorf("ошибка при копировании файла в архив: %w", err)
+	}
+	a.entries = append(a.entries, repository.ArchiveEntry{Name: src.name, Size: header.Size})
+	return nil
+}
+
+func (a *tarArchive) Close() error {
+	if err := a.w.Close(); err != nil {
+		return err
+	}
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		if err := a.closers[i].Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *tarArchive) Entries() []repository.ArchiveEntry {
+	return a.entries
+}
+
+// MakeArchive собирает архив задачи в указанном формате и сохраняет сведения о нём
+func (s *TasksService) MakeArchive(task repository.Task, format string, cfg *config.Config) (string, error) {
+	enc, err := s.encryption(task, format)
+	if err != nil {
+		return "", err
+	}
+	archiveName := fmt.Sprintf("%s/%d_archive%s", archivesDir, task.Id, fileFormat(format, enc != nil).Ext)
+	if err := os.MkdirAll(archivesDir, os.ModePerm); err != nil {
+		return "", fmt.Errorf("ошибка при создании директории: %w", err)
+	}
+	// архив собирается во временном файле, чтобы по ссылке никогда не отдавался недописанный
+	archiveFile, err := os.Create(archiveName + partSuffix)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при создании архива: %w", err)
+	}
+	defer os.Remove(archiveName + partSuffix)
+	defer archiveFile.Close()
+
+	sums := newChecksummer("")
+	archive, err := writeArchive(io.MultiWriter(archiveFile, sums.Writer()), task, format, cfg, enc, false)
+	if err != nil {
+		return "", err
+	}
+	info, err := archiveFile.Stat()
+	if err != nil {
+		return "", fmt.Errorf("ошибка при записи архива: %w", err)
+	}
+	if err := archiveFile.Close(); err != nil {
+		return "", fmt.Errorf("ошибка при записи архива: %w", err)
+	}
+	if err := os.Rename(archiveName+partSuffix, archiveName); err != nil {
+		return "", fmt.Errorf("ошибка при записи архива: %w", err)
+	}
+
+	archiveInfo := repository.ArchiveInfo{
+		Path:     archiveName,
+		Format:   format,
+		Size:     info.Size(),
+		Checksum: sums.Sum(),
+		Entries:  archive.Entries(),
+	}
+	for _, entry := range archiveInfo.Entries {
+		archiveInfo.OriginalSize += entry.Size
+	}
+	archiveInfo.Ratio = compressionRatio(archiveInfo.Size, archiveInfo.OriginalSize)
+	if archiveInfo.Signature, err = s.signer.Sign(archiveInfo.Checksum); err != nil {
+		return "", err
+	}
+	if err := s.repo.SaveArchive(task.Id, archiveInfo); err != nil {
+		return "", fmt.Errorf("ошибка при обновлении имени архива: %w", err)
+	}
+	return archiveName, nil
+}
+
+// writeArchive пишет в w архив из отчёта о задаче и скачанных файлов в порядке добавления ссылок.
+// При dryRun вместо содержимого файлов пишутся нули: так считается размер архива без чтения файлов
+func writeArchive(w io.Writer, task repository.Task, format string, cfg *config.Config, enc *archiveEncryption, dryRun bool) (archiveWriter, error) {
+	policy := newCompressionPolicy(task.Archive.Compression, task.Archive.CompressionLevel, cfg)
+	archive, err := newArchiveWriter(w, format, policy, enc)
+	if err != nil {
+		return nil, err
+	}
+	names := archiveNames(task)
+	reports, err := reportEntries(task, names)
+	if err != nil {
+		return nil, err
+	}
+	for _, src := range reports {
+		if err := archive.Add(src); err != nil {
+			return nil, fmt.Errorf("ошибка при добавлении %s в архив: %w", src.name, err)
+		}
+	}
+	for i, file := range task.Files {
+		name, ok := names[i]
+		if !ok {
+			continue
+		}
+		if err := addFileToArchive(archive, file, name, dryRun); err != nil {
+			return nil, fmt.Errorf("ошибка при добавлении файла %s в архив: %w", file.Path, err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return nil, fmt.Errorf("ошибка при записи архива: %w", err)
+	}
+	return archive, nil
+}
+
+// uniqueName не даёт двум файлам с одинаковым именем перезаписать друг друга в архиве
+func uniqueName(used map[string]bool, name string) string {
+	ext := path.Ext(name)
+	base := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; used[candidate]; i++ {
+		candidate = fmt.Sprintf("%s (%d)%s", base, i, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+func addFileToArchive(archive archiveWriter, taskFile repository.File, name string, dryRun bool) error {
+	file, err := os.Open(taskFile.Path)
+	if err != nil {
+		return fmt.Errorf("ошибка при открытии файла: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("ошибка при чтении файла: %w", err)
+	}
+	src := entrySource{
+		name:        name,
+		comment:     taskFile.Source,
+		contentType: taskFile.ContentType,
+		size:        info.Size(),
+		modTime:     entryTime(taskFile.LastModified),
+		content:     file,
+		data:        file,
+	}
+	if dryRun {
+		src.data = io.LimitReader(zeroReader{}, info.Size())
+	}
+	return archive.Add(src)
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// Archive - архив задачи для скачивания: готовый файл Path или, в потоковом режиме,
+// архив, который собирается методом Stream при каждом скачивании
+type Archive struct {
+	Format      string
+	FileName    string
+	ContentType string
+	Path        string
+	// Size - размер потокового архива или -1, если его нельзя узнать заранее
+	Size int64
+
+	task repository.Task
+	cfg  *config.Config
+	enc  *archiveEncryption
+}
+
+// Stream пишет архив в w, читая скачанные файлы задачи
+func (a *Archive) Stream(w io.Writer) error {
+	_, err := writeArchive(w, a.task, a.Format, a.cfg, a.enc, false)
+	return err
+}
+
+// GetArchive возвращает архив задачи. Пустой формат - формат задачи. В режиме file архивы
+// в других форматах собираются при первом запросе из уже скачанных файлов и кэшируются
+func (s *TasksService) GetArchive(id int64, format string, cfg *config.Config) (*Archive, error) {
+	task, err := s.repo.GetTask(id)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить задачу: %w", err)
+	}
+	if !task.ArchiveReady() {
+		return nil, fmt.Errorf("%w: задача %d", ErrArchiveNotFound, id)
+	}
+	if format == "" {
+		format = task.Archive.Format
+	}
+	if _, ok := archiveFormats[format]; !ok {
+		return nil, fmt.Errorf("%w: неизвестный формат %s", ErrArchiveFormat, format)
+	}
+	enc, err := s.encryption(task, format)
+	if err != nil {
+		return nil, err
+	}
+	f := fileFormat(format, enc != nil)
+	archive := &Archive{Format: format, FileName: "archive" + f.Ext, ContentType: f.ContentType}
+
+	if task.Archive.Mode == ArchiveModeStream {
+		if format == task.Archive.Format && task.ArchiveInfo.Checksum != "" {
+			// архив воспроизводим, и его размер уже посчитан при подготовке
+			archive.Size = task.ArchiveInfo.Size
+		} else if archive.Size, err = streamSize(task, format, cfg, enc); err != nil {
+			return nil, err
+		}
+		archive.task, archive.cfg, archive.enc = task, cfg, enc
+		return archive, nil
+	}
+	if format == task.Archive.Format {
+		archive.Path = task.ArchivePath
+		return archive, nil
+	}
+	if info, ok := task.Archives[format]; ok {
+		archive.Path = info.Path
+		return archive, nil
+	}
+
+	// один формат одной задачи собирается только одним запросом, остальные ждут его
+	lock, _ := s.archiveLocks.LoadOrStore(fmt.Sprintf("%d/%s", id, format), &sync.Mutex{})
+	lock.(*sync.Mutex).Lock()
+	defer lock.(*sync.Mutex).Unlock()
+
+	if task, err = s.repo.GetTask(id); err != nil {
+		return nil, fmt.Errorf("не удалось получить задачу: %w", err)
+	}
+	if info, ok := task.Archives[format]; ok {
+		archive.Path = info.Path
+		return archive, nil
+	}
+	if archive.Path, err = s.MakeArchive(task, format, cfg); err != nil {
+		return nil, err
+	}
+	return archive, nil
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"backend/internal/config"
+	"backend/internal/repository"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newArchiveTestTask создаёт в репозитории задачу с тремя скачанными файлами и одной неудачной ссылкой.
+// Рабочая директория теста меняется на временную: архивы пишутся в archivesDir относительно неё
+func newArchiveTestTask(t *testing.T, archive repository.ArchiveOptions) (*TasksService, repository.Task, *config.Config) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Chdir(dir)
+
+	repo := repository.NewTasksRepository()
+	id, err := repo.CreateTask(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []struct {
+		name, link, content string
+		lastModified        time.Time
+	}{
+		{"report.pdf", "https://a.example/report.pdf", "%PDF-1.4 " + string(bytes.Repeat([]byte("report "), 500)), time.Date(2021, 3, 4, 5, 6, 8, 0, time.UTC)},
+		{"notes.txt", "https://b.example/notes.txt", "заметки\n", time.Time{}},
+		{"report.pdf", "https://b.example/report.pdf", "%PDF-1.4 other", time.Date(1975, 1, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for i, f := range files {
+		path := filepath.Join(dir, f.name+string(rune('0'+i)))
+		if err := os.WriteFile(path, []byte(f.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		_, err := repo.AppendFile(id, repository.File{
+			Link:         f.link,
+			Source:       f.link,
+			Name:         f.name,
+			Path:         path,
+			Status:       repository.FileLoaded,
+			Size:         int64(len(f.content)),
+			LastModified: f.lastModified,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	repo.AppendFile(id, repository.File{Link: "https://c.example/missing.pdf", Status: repository.FileFailed, Error: "файл не найден"})
+
+	task, err := repo.GetTask(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{}
+	return &TasksService{repo: repo}, task, cfg
+}
+
+func TestMakeArchiveIsReproducible(t *testing.T) {
+	for _, format := range []string{ArchiveZip, ArchiveTar, ArchiveTarGz, ArchiveTarZst} {
+		t.Run(format, func(t *testing.T) {
+			s, task, cfg := newArchiveTestTask(t, repository.ArchiveOptions{Format: format, Compression: CompressionAuto, CompressionLevel: 6})
+
+			build := func() ([]byte, repository.ArchiveInfo) {
+				path, err := s.MakeArchive(task, format, cfg)
+				if err != nil {
+					t.Fatalf("MakeArchive: %v", err)
+				}
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				saved, err := s.repo.GetTask(task.Id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return data, *saved.ArchiveInfo
+			}
+
+			first, firstInfo := build()
+			// время изменения файлов на диске в архив не попадает
+			for _, f := range task.Files {
+				if f.Path != "" {
+					os.Chtimes(f.Path, time.Now(), time.Now())
+				}
+			}
+			second, secondInfo := build()
+
+			if !bytes.Equal(first, second) {
+				t.Fatalf("две сборки различаются: %d и %d байт", len(first), len(second))
+			}
+			sum := sha256.Sum256(first)
+			if want := "sha256:" + hex.EncodeToString(sum[:]); firstInfo.Checksum != want || secondInfo.Checksum != want {
+				t.Errorf("checksum %s / %s, SHA-256 файла %s", firstInfo.Checksum, secondInfo.Checksum, want)
+			}
+			if firstInfo.Size != int64(len(first)) {
+				t.Errorf("size = %d, файл %d байт", firstInfo.Size, len(first))
+			}
+		})
+	}
+}
+
+func TestArchiveEntriesMetadata(t *testing.T) {
+	s, task, cfg := newArchiveTestTask(t, repository.ArchiveOptions{Format: ArchiveZip, Compression: CompressionDeflate, CompressionLevel: 6})
+	path, err := s.MakeArchive(task, ArchiveZip, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	want := []struct {
+		name, comment string
+		modified      time.Time
+	}{
+		{manifestName, "", archiveEpoch},
+		{reportName, "", archiveEpoch},
+		{indexName, "", archiveEpoch},
+		{"report.pdf", "https://a.example/report.pdf", time.Date(2021, 3, 4, 5, 6, 8, 0, time.UTC)},
+		{"notes.txt", "https://b.example/notes.txt", archiveEpoch},
+		{"report (2).pdf", "https://b.example/report.pdf", archiveEpoch},
+	}
+	if len(zr.File) != len(want) {
+		t.Fatalf("%d записей, ожидалось %d", len(zr.File), len(want))
+	}
+	for i, f := range zr.File {
+		if f.Name != want[i].name || f.Comment != want[i].comment || !f.Modified.Equal(want[i].modified) {
+			t.Errorf("запись %d: %q %q %v, ожидалось %+v", i, f.Name, f.Comment, f.Modified, want[i])
+		}
+		if f.Mode().Perm() != entryMode {
+			t.Errorf("%s: права %v", f.Name, f.Mode())
+		}
+	}
+}
+
+func TestStreamSizeMatchesArchive(t *testing.T) {
+	for _, format := range []string{ArchiveTar, ArchiveZip} {
+		t.Run(format, func(t *testing.T) {
+			_, task, cfg := newArchiveTestTask(t, repository.ArchiveOptions{Format: format, Compression: CompressionStore})
+			size, err := streamSize(task, format, cfg, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if _, err := writeArchive(&buf, task, format, cfg, nil, false); err != nil {
+				t.Fatal(err)
+			}
+			if size != int64(buf.Len()) {
+				t.Errorf("streamSize = %d, архив %d байт", size, buf.Len())
+			}
+			if format == ArchiveTar {
+				tr := tar.NewReader(&buf)
+				header, err := tr.Next()
+				if err != nil || header.Name != manifestName {
+					t.Errorf("первая запись tar: %v, %v", header, err)
+				}
+			}
+		})
+	}
+}
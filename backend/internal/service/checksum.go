@@ -0,0 +1,131 @@
+package service
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+)
+
+var errChecksumMismatch = errors.New("контрольная сумма не совпадает")
+
+// checksumError - несовпадение контрольной суммы. Sum - SHA-256 скачанного содержимого,
+// Actual - его сумма в алгоритме ожидаемой
+type checksumError struct {
+	err    error
+	Sum    string
+	Actual string
+}
+
+func (e *checksumError) Error() string { return e.err.Error() }
+func (e *checksumError) Unwrap() error { return e.err }
+
+// поддерживаемые алгоритмы и длина хэша в шестнадцатеричном виде
+var checksumLengths = map[string]int{
+	"md5":    32,
+	"sha256": 64,
+	"sha512": 128,
+}
+
+// normalizeChecksum приводит контрольную сумму к виду algo:hex. Принимает sha256:…, sha-256:…
+// и хэш без алгоритма, тогда алгоритм определяется по длине
+func normalizeChecksum(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", nil
+	}
+
+	algo, sum, ok := strings.Cut(value, ":")
+	if !ok {
+		algo, sum = "", value
+		for name, length := range checksumLengths {
+			if len(sum) == length {
+				algo = name
+			}
+		}
+		if algo == "" {
+			return "", fmt.Errorf("не удалось определить алгоритм контрольной суммы по длине %d", len(sum))
+		}
+	}
+	algo = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(algo)), "-", "")
+	sum = strings.ToLower(strings.TrimSpace(sum))
+
+	length, ok := checksumLengths[algo]
+	if !ok {
+		return "", fmt.Errorf("неподдерживаемый алгоритм контрольной суммы: %s, поддерживаются md5, sha256, sha512", algo)
+	}
+	if _, err := hex.DecodeString(sum); err != nil || len(sum) != length {
+		return "", fmt.Errorf("некорректная контрольная сумма %s", algo)
+	}
+	return algo + ":" + sum, nil
+}
+
+func newHash(algo string) hash.Hash {
+	switch algo {
+	case "md5":
+		return md5.New()
+	case "sha512":
+		return sha512.New()
+	default:
+		return sha256.New()
+	}
+}
+
+// checksummer считает SHA-256 содержимого и, если клиент передал контрольную сумму
+// другого алгоритма, ещё и её - за один проход при записи файла
+type checksummer struct {
+	expected string
+	sha256   hash.Hash
+	other    hash.Hash
+}
+
+func newChecksummer(expected string) *checksummer {
+	c := &checksummer{expected: expected, sha256: sha256.New()}
+	if algo, _, _ := strings.Cut(expected, ":"); expected != "" && algo != "sha256" {
+		c.other = newHash(algo)
+	}
+	return c
+}
+
+func (c *checksummer) Writer() io.Writer {
+	if c.other == nil {
+		return c.sha256
+	}
+	return io.MultiWriter(c.sha256, c.other)
+}
+
+// Sum возвращает SHA-256 в виде sha256:hex
+func (c *checksummer) Sum() string {
+	return "sha256:" + hex.EncodeToString(c.sha256.Sum(nil))
+}
+
+// Actual возвращает сумму в алгоритме ожидаемой в виде algo:hex или пустую строку,
+// если ожидаемая сумма не задана
+func (c *checksummer) Actual() string {
+	if c.expected == "" {
+		return ""
+	}
+	algo, _, _ := strings.Cut(c.expected, ":")
+	h := c.sha256
+	if c.other != nil {
+		h = c.other
+	}
+	return algo + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Verify сравнивает посчитанную сумму с ожидаемой
+func (c *checksummer) Verify() error {
+	if actual := c.Actual(); actual != c.expected {
+		return &checksumError{
+			err:    fmt.Errorf("%w: ожидалась %s, получена %s", errChecksumMismatch, c.expected, actual),
+			Sum:    c.Sum(),
+			Actual: actual,
+		}
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/fetcher"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeChecksum(t *testing.T) {
+	sha := sha256.Sum256([]byte("x"))
+	md := md5.Sum([]byte("x"))
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"sha256:" + hex.EncodeToString(sha[:]), "sha256:" + hex.EncodeToString(sha[:])},
+		{"SHA256:" + hex.EncodeToString(sha[:]), "sha256:" + hex.EncodeToString(sha[:])},
+		{hex.EncodeToString(sha[:]), "sha256:" + hex.EncodeToString(sha[:])},
+		{hex.EncodeToString(md[:]), "md5:" + hex.EncodeToString(md[:])},
+	}
+	for _, tt := range tests {
+		got, err := normalizeChecksum(tt.in)
+		if err != nil || got != tt.want {
+			t.Errorf("normalizeChecksum(%q) = %q, %v, ожидалось %q", tt.in, got, err, tt.want)
+		}
+	}
+	for _, bad := range []string{"sha256:xyz", "crc32:00000000", "abc"} {
+		if _, err := normalizeChecksum(bad); err == nil {
+			t.Errorf("normalizeChecksum(%q): ожидалась ошибка", bad)
+		}
+	}
+}
+
+func newDownloadTestService(t *testing.T) (*TasksService, *config.Config) {
+	t.Helper()
+	// файлы скачиваются в staticDir относительно рабочей директории
+	t.Chdir(t.TempDir())
+	cfg := &config.Config{AllowedExtensions: ".txt"}
+	fetchers, err := fetcher.NewRegistry(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &TasksService{fetchers: fetchers}, cfg
+}
+
+func TestDownloadVerifiesChecksum(t *testing.T) {
+	s, cfg := newDownloadTestService(t)
+	content := sha256.Sum256([]byte("hello"))
+	link := Link{Name: "hello.txt", Checksum: "sha256:" + hex.EncodeToString(content[:])}
+
+	result, err := s.download(1, 0, "data:,hello", link, cfg)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if result.Checksum != link.Checksum || result.Size != 5 {
+		t.Errorf("checksum = %s, size = %d", result.Checksum, result.Size)
+	}
+	if data, err := os.ReadFile(result.Path); err != nil || string(data) != "hello" {
+		t.Errorf("файл: %q, %v", data, err)
+	}
+}
+
+func TestDownloadChecksumMismatchRemovesFile(t *testing.T) {
+	s, cfg := newDownloadTestService(t)
+	wrong := sha256.Sum256([]byte("something else"))
+	link := Link{Name: "hello.txt", Checksum: "sha256:" + hex.EncodeToString(wrong[:])}
+
+	_, err := s.download(1, 0, "data:,hello", link, cfg)
+	var mismatch *checksumError
+	if !errors.As(err, &mismatch) || !errors.Is(err, errChecksumMismatch) {
+		t.Fatalf("ожидалась ошибка несовпадения суммы, получено %v", err)
+	}
+	var permanent permanentError
+	if !errors.As(err, &permanent) {
+		t.Errorf("несовпадение суммы должно быть постоянной ошибкой: %v", err)
+	}
+	got := sha256.Sum256([]byte("hello"))
+	if mismatch.Sum != "sha256:"+hex.EncodeToString(got[:]) {
+		t.Errorf("Sum = %s", mismatch.Sum)
+	}
+
+	entries, err := os.ReadDir(staticDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		t.Errorf("после несовпадения суммы остался файл %s", filepath.Join(staticDir, entry.Name()))
+	}
+}
+
+func TestDownloadChecksumMD5(t *testing.T) {
+	s, cfg := newDownloadTestService(t)
+	sum := md5.Sum([]byte("hello"))
+	link := Link{Name: "hello.txt", Checksum: "md5:" + hex.EncodeToString(sum[:])}
+	result, err := s.download(1, 0, "data:,hello", link, cfg)
+	if err != nil {
+		t.Fatalf("md5: %v", err)
+	}
+	sha := sha256.Sum256([]byte("hello"))
+	if result.ActualChecksum != link.Checksum || result.Checksum != "sha256:"+hex.EncodeToString(sha[:]) {
+		t.Errorf("actual = %s, checksum = %s", result.ActualChecksum, result.Checksum)
+	}
+	link.Checksum = "md5:00000000000000000000000000000000"
+	_, err = s.download(1, 1, "data:,hello", link, cfg)
+	var mismatch *checksumError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("md5 не совпадает, ошибка = %v", err)
+	}
+	if mismatch.Actual != "md5:"+hex.EncodeToString(sum[:]) {
+		t.Errorf("Actual = %s", mismatch.Actual)
+	}
+}
+
+func TestDownloadChecksumSHA512(t *testing.T) {
+	s, cfg := newDownloadTestService(t)
+	sum := sha512.Sum512([]byte("hello"))
+	link := Link{Name: "hello.txt", Checksum: "sha512:" + hex.EncodeToString(sum[:])}
+	result, err := s.download(1, 0, "data:,hello", link, cfg)
+	if err != nil {
+		t.Fatalf("sha512: %v", err)
+	}
+	if result.ActualChecksum != link.Checksum {
+		t.Errorf("actual = %s, ожидалось %s", result.ActualChecksum, link.Checksum)
+	}
+
+	result, err = s.download(1, 1, "data:,hello", Link{Name: "hello.txt"}, cfg)
+	if err != nil || result.ActualChecksum != "" {
+		t.Errorf("без ожидаемой суммы: actual = %q, %v", result.ActualChecksum, err)
+	}
+}
+
+func TestWriteAtomicallyVerifiesBeforeRename(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "file.txt")
+	verify := func() error {
+		if _, err := os.Stat(name); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("файл появился под итоговым именем до проверки суммы: %v", err)
+		}
+		if _, err := os.Stat(name + partSuffix); err != nil {
+			t.Errorf("нет временного файла во время проверки: %v", err)
+		}
+		return &checksumError{err: errChecksumMismatch}
+	}
+
+	_, err := writeAtomically(name, strings.NewReader("hello"), 5, io.Discard, verify)
+	if !errors.Is(err, errChecksumMismatch) {
+		t.Fatalf("ошибка = %v, ожидалось несовпадение суммы", err)
+	}
+	for _, p := range []string{name, name + partSuffix} {
+		if _, err := os.Stat(p); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("после несовпадения суммы остался %s", p)
+		}
+	}
+}
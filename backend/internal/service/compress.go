@@ -0,0 +1,112 @@
+package service
+
+import (
+	"backend/internal/config"
+	"compress/flate"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"path"
+	"strings"
+)
+
+const (
+	CompressionAuto    = "auto"
+	CompressionDeflate = "deflate"
+	CompressionStore   = "store"
+
+	// compressionSampleSize - сколько байт файла сжимается в режиме auto, чтобы оценить сжимаемость
+	compressionSampleSize = 64 << 10
+	// autoStoreRatio - если проба сжимается хуже, файл кладётся без сжатия
+	autoStoreRatio = 0.9
+)
+
+// compressionPolicy выбирает метод сжатия для каждой записи zip
+type compressionPolicy struct {
+	mode       string
+	level      int
+	storeTypes []string
+}
+
+func newCompressionPolicy(mode string, level int, cfg *config.Config) compressionPolicy {
+	return compressionPolicy{mode: mode, level: level, storeTypes: cfg.Archives.StoreTypes}
+}
+
+// Store сообщает, что файл нужно положить в архив без сжатия: так выбрано для задачи,
+// тип содержимого уже сжат или, в режиме auto, проба файла почти не сжимается
+func (p compressionPolicy) Store(content io.ReaderAt, name, contentType string) (bool, error) {
+	switch {
+	case p.mode == CompressionStore:
+		return true, nil
+	case p.compressedType(name, contentType):
+		return true, nil
+	case p.mode == CompressionAuto:
+		ratio, err := sampleRatio(content)
+		if err != nil {
+			return false, err
+		}
+		return ratio > autoStoreRatio, nil
+	default:
+		return false, nil
+	}
+}
+
+// compressedType проверяет MIME-тип файла по списку ARCHIVE_STORE_TYPES
+func (p compressionPolicy) compressedType(name, contentType string) bool {
+	mediaType := fileMediaType(name, contentType)
+	if mediaType == "" {
+		return false
+	}
+	for _, pattern := range p.storeTypes {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == mediaType || strings.HasSuffix(pattern, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(pattern, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileMediaType возвращает MIME-тип файла без параметров. Если сервер не сообщил тип
+// или сообщил слишком общий, тип определяется по расширению
+func fileMediaType(name, contentType string) string {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" || genericContentTypes[mediaType] {
+		mediaType, _, _ = mime.ParseMediaType(mime.TypeByExtension(strings.ToLower(path.Ext(name))))
+	}
+	return mediaType
+}
+
+// sampleRatio сжимает начало файла самым быстрым уровнем и возвращает отношение размеров.
+// Файл читается через ReadAt, позиция чтения не меняется
+func sampleRatio(content io.ReaderAt) (float64, error) {
+	sample := make([]byte, compressionSampleSize)
+	n, err := content.ReadAt(sample, 0)
+	if err != nil && err != io.EOF {
+		return 0, fmt.Errorf("ошибка при чтении файла: %w", err)
+	}
+	if n == 0 {
+		return 1, nil
+	}
+
+	var counter countingWriter
+	w, _ := flate.NewWriter(&counter, flate.BestSpeed)
+	w.Write(sample[:n])
+	w.Close()
+	return float64(counter) / float64(n), nil
+}
+
+type countingWriter int64
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	*c += countingWriter(len(p))
+	return len(p), nil
+}
+
+// compressionRatio - отношение сжатого размера к исходному, округлённое до тысячных
+func compressionRatio(compressed, original int64) float64 {
+	if original == 0 {
+		return 0
+	}
+	return math.Round(float64(compressed)/float64(original)*1000) / 1000
+}
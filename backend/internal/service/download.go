@@ -0,0 +1,223 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/fetcher"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	staticDir  = "./backend/static"
+	partSuffix = ".part"
+)
+
+type downloadResult struct {
+	Name      string
+	Path      string
+	FinalURL  string
+	Redirects []string
+	// ContentType и LastModified - тип и время изменения по ответу сервера, Size - размер скачанного файла
+	ContentType  string
+	LastModified time.Time
+	Size         int64
+	// Checksum - SHA-256 скачанного файла в виде sha256:hex, ActualChecksum - сумма
+	// в алгоритме ожидаемой, если она задана
+	Checksum       string
+	ActualChecksum string
+}
+
+// permanentError - ошибка, которую бессмысленно повторять (неверный формат, 404 и т.п.)
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+func (s *TasksService) downloadWithRetries(id int64, idx int, candidate string, link Link, log *slog.Logger, cfg *config.Config) (*downloadResult, error) {
+	delay := cfg.Downloads.RetryDelay
+	for attempt := 0; ; attempt++ {
+		result, err := s.download(id, idx, candidate, link, cfg)
+		if err == nil {
+			return result, nil
+		}
+
+		var permanent permanentError
+		if errors.As(err, &permanent) || attempt >= cfg.Downloads.Retries {
+			return nil, err
+		}
+
+		log.Warn("Повтор загрузки", slog.Int64("task_id", id), slog.Int("attempt", attempt+1), slog.String("error", err.Error()))
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// download скачивает candidate - основную ссылку или одно из зеркал link.
+// Если имя файла не задано, оно выбирается по ссылке и ответу сервера (см. fileNameFor)
+func (s *TasksService) download(id int64, idx int, candidate string, link Link, cfg *config.Config) (*downloadResult, error) {
+	u, err := url.Parse(candidate)
+	if err != nil {
+		return nil, permanentError{fmt.Errorf("некорректная ссылка: %w", err)}
+	}
+	// имя, заданное клиентом, проверяется до запроса: ответ сервера его не меняет
+	if link.Name != "" && !allowedExtension(link.Name, cfg) {
+		return nil, permanentError{errFileFormat(cfg)}
+	}
+
+	resp, err := s.fetchers.Fetch(context.Background(), newFetchRequest(u, link.Options))
+	if err != nil {
+		var status *fetcher.StatusError
+		if errors.As(err, &status) && !status.Temporary() || errors.Is(err, fetcher.ErrFTPControlChars) || errors.Is(err, fetcher.ErrFTPProxy) {
+			err = permanentError{err}
+		}
+		return nil, fmt.Errorf("ошибка при скачивании файла: %w", err)
+	}
+	defer resp.Body.Close()
+
+	name, ok := fileNameFor(link, u, resp, cfg)
+	if !ok {
+		return nil, permanentError{errFileFormat(cfg)}
+	}
+
+	if err := os.MkdirAll(staticDir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("ошибка при создании директории: %w", err)
+	}
+	fileName := fmt.Sprintf("%s/%d_%d_%s", staticDir, id, idx, name)
+	sums := newChecksummer(link.Checksum)
+	size, err := writeAtomically(fileName, resp.Body, resp.ContentLength, sums.Writer(), sums.Verify)
+	if err != nil {
+		var mismatch *checksumError
+		if errors.As(err, &mismatch) {
+			err = permanentError{err}
+		}
+		return nil, err
+	}
+
+	return &downloadResult{
+		Name:           name,
+		Path:           fileName,
+		FinalURL:       resp.FinalURL,
+		Redirects:      resp.Redirects,
+		ContentType:    resp.ContentType,
+		LastModified:   resp.LastModified,
+		Size:           size,
+		Checksum:       sums.Sum(),
+		ActualChecksum: sums.Actual(),
+	}, nil
+}
+
+// fileNameFor возвращает имя, под которым сохраняется файл, и есть ли у него разрешённое расширение.
+// Имя из link.Name берётся как есть. Если у имени из ссылки нет разрешённого расширения (например,
+// /uc?export=download&id=...), используется имя из Content-Disposition, а если и его нет -
+// к имени из ссылки добавляется расширение по Content-Type
+func fileNameFor(link Link, u *url.URL, resp *fetcher.Response, cfg *config.Config) (string, bool) {
+	if link.Name != "" {
+		return link.Name, allowedExtension(link.Name, cfg)
+	}
+	name := cleanFileName(fetcher.FileName(u))
+	if allowedExtension(name, cfg) {
+		return name, true
+	}
+	if _, params, err := mime.ParseMediaType(resp.ContentDisposition); err == nil {
+		if attached := cleanFileName(path.Base(params["filename"])); allowedExtension(attached, cfg) {
+			return attached, true
+		}
+	}
+	if ext := fetcher.ExtensionByType(resp.ContentType); ext != "" && allowedExtension(ext, cfg) {
+		if name == "" {
+			name = "file"
+		}
+		return name + ext, true
+	}
+	return name, false
+}
+
+// allowedExtension проверяет, что имя файла оканчивается одним из ALLOWED_EXTENSIONS
+func allowedExtension(name string, cfg *config.Config) bool {
+	for _, ext := range strings.Split(cfg.AllowedExtensions, ",") {
+		if ext = strings.TrimSpace(ext); ext != "" && strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func errFileFormat(cfg *config.Config) error {
+	return fmt.Errorf("неверный формат файла, поддерживаются только %s", cfg.AllowedExtensions)
+}
+
+// writeAtomically пишет содержимое во временный файл name.part, сверяет размер с Content-Length,
+// вызывает verify (проверку контрольной суммы), сбрасывает данные на диск и только после этого
+// переименовывает файл. Недокачанный или не прошедший проверку файл никогда не появляется
+// под итоговым именем. Возвращает размер записанного файла
+func writeAtomically(name string, body io.Reader, contentLength int64, extra io.Writer, verify func() error) (written int64, err error) {
+	part := name + partSuffix
+	out, err := os.Create(part)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при создании файла: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			out.Close()
+			os.Remove(part)
+		}
+	}()
+
+	written, err = io.Copy(io.MultiWriter(out, extra), body)
+	if err != nil {
+		return 0, fmt.Errorf("ошибка при сохранении файла: %w", err)
+	}
+	if contentLength >= 0 && written != contentLength {
+		return 0, fmt.Errorf("файл скачан не полностью: получено %d байт из %d", written, contentLength)
+	}
+	if err := verify(); err != nil {
+		return 0, err
+	}
+	if err := out.Sync(); err != nil {
+		return 0, fmt.Errorf("ошибка при сохранении файла: %w", err)
+	}
+	if err := out.Close(); err != nil {
+		return 0, fmt.Errorf("ошибка при сохранении файла: %w", err)
+	}
+	if err := os.Rename(part, name); err != nil {
+		return 0, fmt.Errorf("ошибка при сохранении файла: %w", err)
+	}
+	return written, nil
+}
+
+// CleanupPartialDownloads удаляет файлы .part, оставшиеся после аварийной остановки
+func CleanupPartialDownloads(log *slog.Logger) {
+	entries, err := os.ReadDir(staticDir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warn("Не удалось прочитать директорию загрузок", slog.String("error", err.Error()))
+		}
+		return
+	}
+	removed := 0
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), partSuffix) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(staticDir, entry.Name())); err != nil {
+			log.Warn("Не удалось удалить недокачанный файл", slog.String("file", entry.Name()), slog.String("error", err.Error()))
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		log.Info("Удалены недокачанные файлы", slog.Int("count", removed))
+	}
+}
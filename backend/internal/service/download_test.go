@@ -0,0 +1,90 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/fetcher"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestDownloadRewrittenLink проводит ссылку на страницу просмотра через правило переписывания
+// и скачивание: у итоговой ссылки /uc?export=download&id=... нет расширения
+func TestDownloadRewrittenLink(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("id") {
+		case "typed":
+			w.Header().Set("Content-Type", "application/pdf")
+		case "attached":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Header().Set("Content-Disposition", `attachment; filename*=UTF-8''%D0%9E%D1%82%D1%87%D1%91%D1%82.pdf`)
+		case "archive":
+			w.Header().Set("Content-Type", "application/zip")
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, "%PDF-1.4 "+r.URL.Query().Get("id"))
+	}))
+	defer server.Close()
+
+	t.Chdir(t.TempDir())
+	cfg := &config.Config{AllowedExtensions: ".pdf,.txt"}
+	fetchers, err := fetcher.NewRegistry(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rewriter, err := newTestRewriter(t, `[{
+		"match": "^https://drive\\.example\\.com/file/d/([^/]+)/.*$",
+		"replace": "`+server.URL+`/uc?export=download&id=$1"
+	}]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewTasksService(nil, fetchers, rewriter, nil)
+
+	tests := []struct {
+		id   string
+		name string
+		want string
+		err  bool
+	}{
+		{id: "typed", want: "uc.pdf"},
+		{id: "attached", want: "Отчёт.pdf"},
+		{id: "typed", name: "Отчёт за год.pdf", want: "Отчёт за год.pdf"},
+		{id: "archive", err: true},
+		{id: "typed", name: "report.zip", err: true},
+	}
+	for _, tt := range tests {
+		pending, err := s.prepareLink(Link{URL: "https://drive.example.com/file/d/" + tt.id + "/view?usp=sharing", Name: tt.name})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.id, err)
+		}
+		if !strings.HasPrefix(pending.link.URL, server.URL+"/uc?") {
+			t.Fatalf("%s: ссылка не переписана: %s", tt.id, pending.link.URL)
+		}
+		result, err := s.download(1, 0, pending.link.URL, pending.link, cfg)
+		if tt.err {
+			var permanent permanentError
+			if !errors.As(err, &permanent) || !strings.Contains(err.Error(), "неверный формат файла") {
+				t.Errorf("%s, %q: ошибка = %v, ожидался неверный формат", tt.id, tt.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s, %q: %v", tt.id, tt.name, err)
+			continue
+		}
+		if result.Name != tt.want {
+			t.Errorf("%s, %q: имя %q, ожидалось %q", tt.id, tt.name, result.Name, tt.want)
+		}
+		if data, err := os.ReadFile(result.Path); err != nil || string(data) != "%PDF-1.4 "+tt.id {
+			t.Errorf("%s: файл %q, %v", tt.id, data, err)
+		}
+	}
+}
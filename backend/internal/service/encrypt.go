@@ -0,0 +1,129 @@
+package service
+
+import (
+	"backend/internal/repository"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"filippo.io/age"
+)
+
+// archiveEncryption - параметры шифрования при сборке архива. zip шифруется паролем (WinZip AE-2),
+// tar - целиком через age паролем или ключом получателя
+type archiveEncryption struct {
+	passphrase string
+	recipient  age.Recipient
+}
+
+// encryptedExt - расширение tar-архива, зашифрованного age
+const encryptedExt = ".age"
+
+var errRecipientZip = errors.New("zip шифруется только паролем, для ключа получателя выберите tar, tar.gz или tar.zst")
+
+// secretBox хранит пароли архивов в задачах только в зашифрованном виде. Ключ создаётся
+// при запуске и живёт только в памяти процесса
+type secretBox struct {
+	aead cipher.AEAD
+}
+
+func newSecretBox() *secretBox {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("не удалось создать ключ для паролей архивов: %v", err))
+	}
+	block, _ := aes.NewCipher(key)
+	aead, _ := cipher.NewGCM(block)
+	return &secretBox{aead: aead}
+}
+
+func (b *secretBox) Seal(secret string) []byte {
+	nonce := make([]byte, b.aead.NonceSize())
+	rand.Read(nonce)
+	return b.aead.Seal(nonce, nonce, []byte(secret), nil)
+}
+
+func (b *secretBox) Open(sealed []byte) (string, error) {
+	if len(sealed) < b.aead.NonceSize() {
+		return "", fmt.Errorf("повреждён пароль архива")
+	}
+	nonce, ciphertext := sealed[:b.aead.NonceSize()], sealed[b.aead.NonceSize():]
+	secret, err := b.aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("повреждён пароль архива: %w", err)
+	}
+	return string(secret), nil
+}
+
+// validateEncryption проверяет пароль и ключ получателя. Ключ получателя - открытый ключ age (age1...)
+func validateEncryption(archive repository.ArchiveOptions) error {
+	if archive.Passphrase != "" && archive.Recipient != "" {
+		return fmt.Errorf("укажите пароль или ключ получателя, но не оба")
+	}
+	if archive.Recipient == "" {
+		return nil
+	}
+	if _, err := age.ParseX25519Recipient(strings.TrimSpace(archive.Recipient)); err != nil {
+		return fmt.Errorf("некорректный ключ получателя age: %w", err)
+	}
+	if archive.Format == ArchiveZip {
+		return errRecipientZip
+	}
+	return nil
+}
+
+// sealPassphrase заменяет пароль архива его зашифрованной копией перед сохранением задачи
+func (s *TasksService) sealPassphrase(archive repository.ArchiveOptions) repository.ArchiveOptions {
+	archive.Recipient = strings.TrimSpace(archive.Recipient)
+	if archive.Passphrase != "" {
+		archive.SealedPassphrase = s.secrets.Seal(archive.Passphrase)
+		archive.Passphrase = ""
+	}
+	archive.Encrypted = archive.SealedPassphrase != nil || archive.Recipient != ""
+	return archive
+}
+
+// encryption возвращает параметры шифрования архива задачи в формате format или nil
+func (s *TasksService) encryption(task repository.Task, format string) (*archiveEncryption, error) {
+	archive := task.Archive
+	switch {
+	case archive.SealedPassphrase != nil:
+		passphrase, err := s.secrets.Open(archive.SealedPassphrase)
+		if err != nil {
+			return nil, err
+		}
+		return &archiveEncryption{passphrase: passphrase}, nil
+	case archive.Recipient != "":
+		if format == ArchiveZip {
+			return nil, fmt.Errorf("%w: %w", ErrArchiveFormat, errRecipientZip)
+		}
+		recipient, err := age.ParseX25519Recipient(archive.Recipient)
+		if err != nil {
+			return nil, fmt.Errorf("некорректный ключ получателя age: %w", err)
+		}
+		return &archiveEncryption{recipient: recipient}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// ageWriter шифрует поток tar через age: паролем (scrypt) или ключом получателя
+func (e *archiveEncryption) ageWriter(w io.Writer) (io.WriteCloser, error) {
+	recipient := e.recipient
+	if recipient == nil {
+		scrypt, err := age.NewScryptRecipient(e.passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("ошибка при настройке шифрования: %w", err)
+		}
+		recipient = scrypt
+	}
+	encrypted, err := age.Encrypt(w, recipient)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при настройке шифрования: %w", err)
+	}
+	return encrypted, nil
+}
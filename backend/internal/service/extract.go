@@ -0,0 +1,271 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/fetcher"
+	"backend/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+)
+
+const (
+	maxExtractDepth = 3
+	maxExtractPages = 100
+	maxPageSize     = 10 << 20
+)
+
+// ExtractOptions - параметры разбора HTML-страницы в режиме extract
+type ExtractOptions struct {
+	// Selector - CSS-селектор элементов, из которых берутся href/src. По умолчанию все a[href] и img[src]
+	Selector string
+	// Pattern - регулярное выражение, которому должна соответствовать абсолютная ссылка на файл
+	Pattern string
+	// Depth - на сколько уровней переходить по ссылкам на другие страницы
+	Depth int
+	// SameHost - не выходить за пределы хоста исходной страницы
+	SameHost bool
+}
+
+type extractor struct {
+	selector cascadia.Selector
+	pattern  *regexp.Regexp
+	opts     ExtractOptions
+	root     *url.URL
+	allowed  []string
+}
+
+func newExtractor(link Link, cfg *config.Config) (*extractor, error) {
+	root, err := url.Parse(link.URL)
+	if err != nil {
+		return nil, fmt.Errorf("некорректная ссылка: %w", err)
+	}
+	if root.Scheme != "http" && root.Scheme != "https" {
+		return nil, fmt.Errorf("в режиме extract поддерживаются только ссылки http(s)")
+	}
+	if link.Extract.Depth < 0 || link.Extract.Depth > maxExtractDepth {
+		return nil, fmt.Errorf("глубина обхода должна быть от 0 до %d", maxExtractDepth)
+	}
+
+	e := &extractor{opts: link.Extract, root: root, allowed: strings.Split(cfg.AllowedExtensions, ",")}
+	if link.Extract.Selector != "" {
+		if e.selector, err = cascadia.Compile(link.Extract.Selector); err != nil {
+			return nil, fmt.Errorf("некорректный CSS-селектор: %w", err)
+		}
+	}
+	if link.Extract.Pattern != "" {
+		if e.pattern, err = regexp.Compile(link.Extract.Pattern); err != nil {
+			return nil, fmt.Errorf("некорректное регулярное выражение: %w", err)
+		}
+	}
+	return e, nil
+}
+
+func (s *TasksService) startExtract(id int64, link Link, log *slog.Logger, cfg *config.Config) error {
+	e, err := newExtractor(link, cfg)
+	if err != nil {
+		return err
+	}
+	if _, err := s.repo.GetTask(id); err != nil {
+		return fmt.Errorf("не удалось получить задачу: %w", err)
+	}
+	if err := s.repo.UpdateTaskStatus(id, repository.TaskProcessing); err != nil {
+		return fmt.Errorf("не удалось обновить статус задачи: %w", err)
+	}
+	if err := s.repo.UpdatePendingJobs(id, 1); err != nil {
+		return fmt.Errorf("не удалось обновить задачу: %w", err)
+	}
+
+	go func() {
+		defer s.finishJob(id, log, cfg)
+		s.extract(id, link, e, log, cfg)
+	}()
+	return nil
+}
+
+// extract обходит страницы в ширину и добавляет найденные файлы в задачу
+func (s *TasksService) extract(id int64, link Link, e *extractor, log *slog.Logger, cfg *config.Config) {
+	type page struct {
+		url   *url.URL
+		depth int
+	}
+
+	queue := []page{{url: e.root}}
+	visited := map[string]bool{e.root.String(): true}
+	found := make(map[string]bool)
+	added := 0
+	pagesLimited := false
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		files, pages, err := s.extractPage(current.url, optionsFor(link.Options, e.root, current.url), e)
+		if err != nil {
+			log.Warn("Не удалось разобрать страницу", slog.Int64("task_id", id), slog.String("page", current.url.Redacted()), slog.String("error", err.Error()))
+			if current.depth == 0 {
+				s.handleTaskErr(fmt.Errorf("не удалось разобрать страницу %s: %w", current.url.Redacted(), err), id, log)
+				return
+			}
+			continue
+		}
+
+		for _, file := range files {
+			if found[file] {
+				continue
+			}
+			found[file] = true
+			opts := link.Options
+			if fileURL, err := url.Parse(file); err == nil {
+				opts = optionsFor(link.Options, e.root, fileURL)
+			}
+			ok, stop := s.appendDiscovered(id, Link{URL: file, Folder: link.Folder, Options: opts}, log, cfg)
+			if stop {
+				return
+			}
+			if ok {
+				added++
+			}
+		}
+
+		if current.depth >= e.opts.Depth {
+			continue
+		}
+		for _, next := range pages {
+			if len(visited) >= maxExtractPages {
+				if !pagesLimited {
+					pagesLimited = true
+					log.Info("Достигнут лимит страниц для обхода", slog.Int64("task_id", id), slog.Int("pages", maxExtractPages))
+				}
+				break
+			}
+			if !visited[next.String()] {
+				visited[next.String()] = true
+				queue = append(queue, page{url: next, depth: current.depth + 1})
+			}
+		}
+	}
+
+	log.Info("Разбор страницы завершён", slog.Int64("task_id", id), slog.Int("files", added))
+	if added == 0 {
+		s.handleTaskErr(fmt.Errorf("на странице %s не найдено подходящих файлов", e.root.Redacted()), id, log)
+	}
+}
+
+// appendDiscovered добавляет в задачу найденную ссылку. stop=true, если достигнут лимит файлов
+func (s *TasksService) appendDiscovered(id int64, link Link, log *slog.Logger, cfg *config.Config) (ok bool, stop bool) {
+	_, err := s.AppendLink(id, link, log, cfg)
+	if errors.Is(err, errFileLimit) {
+		log.Info("Достигнут лимит файлов, обработка остановлена", slog.Int64("task_id", id))
+		return false, true
+	}
+	if err != nil {
+		log.Warn("Найденный файл не добавлен", slog.Int64("task_id", id), slog.String("link", RedactLink(link.URL)), slog.String("error", err.Error()))
+		return false, false
+	}
+	return true, false
+}
+
+// extractPage возвращает ссылки на файлы и ссылки на страницы для дальнейшего обхода
+func (s *TasksService) extractPage(pageURL *url.URL, opts repository.RequestOptions, e *extractor) ([]string, []*url.URL, error) {
+	resp, err := s.fetchers.Fetch(context.Background(), newFetchRequest(pageURL, opts))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.ContentType; ct != "" && !strings.Contains(ct, "html") {
+		return nil, nil, fmt.Errorf("страница не является HTML: %s", ct)
+	}
+	doc, err := html.Parse(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return nil, nil, fmt.Errorf("не удалось разобрать HTML: %w", err)
+	}
+
+	base := pageURL
+	if resp.FinalURL != "" && pageURL.User == nil {
+		if final, err := url.Parse(resp.FinalURL); err == nil {
+			base = final
+		}
+	}
+	if node := cascadia.Query(doc, cascadia.MustCompile("base[href]")); node != nil {
+		if href, err := base.Parse(attr(node, "href")); err == nil {
+			base = href
+		}
+	}
+
+	var nodes []*html.Node
+	if e.selector != nil {
+		nodes = cascadia.QueryAll(doc, e.selector)
+	} else {
+		nodes = cascadia.QueryAll(doc, cascadia.MustCompile("a[href], img[src]"))
+	}
+
+	var files []string
+	for _, node := range nodes {
+		for _, raw := range []string{attr(node, "href"), attr(node, "src")} {
+			if u := e.resolve(base, raw); u != nil && e.isFile(u) {
+				files = append(files, u.String())
+			}
+		}
+	}
+
+	var pages []*url.URL
+	for _, node := range cascadia.QueryAll(doc, cascadia.MustCompile("a[href]")) {
+		if u := e.resolve(base, attr(node, "href")); u != nil && !e.hasAllowedExtension(u) {
+			pages = append(pages, u)
+		}
+	}
+
+	return files, pages, nil
+}
+
+func (e *extractor) resolve(base *url.URL, raw string) *url.URL {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.HasPrefix(raw, "#") {
+		return nil
+	}
+	u, err := base.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil
+	}
+	u.Fragment = ""
+	if e.opts.SameHost && !strings.EqualFold(u.Hostname(), e.root.Hostname()) {
+		return nil
+	}
+	return u
+}
+
+func (e *extractor) isFile(u *url.URL) bool {
+	if !e.hasAllowedExtension(u) {
+		return false
+	}
+	return e.pattern == nil || e.pattern.MatchString(u.String())
+}
+
+func (e *extractor) hasAllowedExtension(u *url.URL) bool {
+	name := fetcher.FileName(u)
+	for _, ext := range e.allowed {
+		if ext != "" && strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+func attr(node *html.Node, name string) string {
+	for _, a := range node.Attr {
+		if a.Key == name {
+			return a.Val
+		}
+	}
+	return ""
+}
@@ -0,0 +1,265 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/fetcher"
+	"backend/internal/repository"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const maxFeedSize = 10 << 20
+
+// FeedOptions - фильтр вложений RSS/Atom-ленты по дате публикации. Нулевая дата - без ограничения
+type FeedOptions struct {
+	Since time.Time
+	Until time.Time
+}
+
+type feedEnclosure struct {
+	URL       string
+	Type      string
+	Title     string
+	Published time.Time
+}
+
+type rssFeed struct {
+	Items []struct {
+		Title     string `xml:"title"`
+		PubDate   string `xml:"pubDate"`
+		Date      string `xml:"http://purl.org/dc/elements/1.1/ date"`
+		Enclosure []struct {
+			URL  string `xml:"url,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"enclosure"`
+	} `xml:"channel>item"`
+}
+
+type atomFeed struct {
+	Entries []struct {
+		Title     string `xml:"title"`
+		Published string `xml:"published"`
+		Updated   string `xml:"updated"`
+		Links     []struct {
+			Href string `xml:"href,attr"`
+			Rel  string `xml:"rel,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"link"`
+	} `xml:"entry"`
+}
+
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC3339,
+	"2006-01-02",
+}
+
+func (s *TasksService) startFeed(id int64, link Link, log *slog.Logger, cfg *config.Config) error {
+	feedURL, err := url.Parse(link.URL)
+	if err != nil {
+		return fmt.Errorf("некорректная ссылка: %w", err)
+	}
+	if !link.Feed.Since.IsZero() && !link.Feed.Until.IsZero() && link.Feed.Until.Before(link.Feed.Since) {
+		return fmt.Errorf("конец периода раньше начала")
+	}
+	if _, err := s.repo.GetTask(id); err != nil {
+		return fmt.Errorf("не удалось получить задачу: %w", err)
+	}
+	if err := s.repo.UpdateTaskStatus(id, repository.TaskProcessing); err != nil {
+		return fmt.Errorf("не удалось обновить статус задачи: %w", err)
+	}
+	if err := s.repo.UpdatePendingJobs(id, 1); err != nil {
+		return fmt.Errorf("не удалось обновить задачу: %w", err)
+	}
+
+	go func() {
+		defer s.finishJob(id, log, cfg)
+		s.ingestFeed(id, feedURL, link, log, cfg)
+	}()
+	return nil
+}
+
+func (s *TasksService) ingestFeed(id int64, feedURL *url.URL, link Link, log *slog.Logger, cfg *config.Config) {
+	enclosures, err := s.readFeed(feedURL, link.Options)
+	if err != nil {
+		s.handleTaskErr(fmt.Errorf("не удалось прочитать ленту %s: %w", feedURL.Redacted(), err), id, log)
+		return
+	}
+
+	allowed := strings.Split(cfg.AllowedExtensions, ",")
+	added := 0
+	for _, enc := range enclosures {
+		if !inPeriod(enc.Published, link.Feed) {
+			continue
+		}
+		encURL, err := feedURL.Parse(strings.TrimSpace(enc.URL))
+		if err != nil {
+			continue
+		}
+		ext := enclosureExtension(encURL, enc.Type, allowed)
+		if ext == "" {
+			continue
+		}
+
+		name := enclosureName(enc.Title, encURL, ext)
+		opts := optionsFor(link.Options, feedURL, encURL)
+		ok, stop := s.appendDiscovered(id, Link{URL: encURL.String(), Name: name, Folder: link.Folder, Options: opts}, log, cfg)
+		if stop {
+			break
+		}
+		if ok {
+			added++
+		}
+	}
+
+	log.Info("Лента обработана", slog.Int64("task_id", id), slog.Int("files", added))
+	if added == 0 {
+		s.handleTaskErr(fmt.Errorf("в ленте %s не найдено подходящих вложений", feedURL.Redacted()), id, log)
+	}
+}
+
+// readFeed скачивает ленту и возвращает вложения из RSS (<enclosure>) или Atom (<link rel="enclosure">)
+func (s *TasksService) readFeed(feedURL *url.URL, opts repository.RequestOptions) ([]feedEnclosure, error) {
+	resp, err := s.fetchers.Fetch(context.Background(), newFetchRequest(feedURL, opts))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxFeedSize))
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении ленты: %w", err)
+	}
+
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("лента не является корректным XML: %w", err)
+	}
+
+	var enclosures []feedEnclosure
+	switch root.XMLName.Local {
+	case "rss":
+		var feed rssFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, fmt.Errorf("не удалось разобрать RSS: %w", err)
+		}
+		for _, item := range feed.Items {
+			published := parseFeedDate(item.PubDate)
+			if published.IsZero() {
+				published = parseFeedDate(item.Date)
+			}
+			for _, enc := range item.Enclosure {
+				enclosures = append(enclosures, feedEnclosure{URL: enc.URL, Type: enc.Type, Title: item.Title, Published: published})
+			}
+		}
+	case "feed":
+		var feed atomFeed
+		if err := xml.Unmarshal(data, &feed); err != nil {
+			return nil, fmt.Errorf("не удалось разобрать Atom: %w", err)
+		}
+		for _, entry := range feed.Entries {
+			published := parseFeedDate(entry.Published)
+			if published.IsZero() {
+				published = parseFeedDate(entry.Updated)
+			}
+			for _, l := range entry.Links {
+				if l.Rel == "enclosure" {
+					enclosures = append(enclosures, feedEnclosure{URL: l.Href, Type: l.Type, Title: entry.Title, Published: published})
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("неизвестный формат ленты: <%s>", root.XMLName.Local)
+	}
+
+	return enclosures, nil
+}
+
+func parseFeedDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// inPeriod проверяет дату публикации. Если период задан, записи без даты отбрасываются
+func inPeriod(published time.Time, opts FeedOptions) bool {
+	if opts.Since.IsZero() && opts.Until.IsZero() {
+		return true
+	}
+	if published.IsZero() {
+		return false
+	}
+	if !opts.Since.IsZero() && published.Before(opts.Since) {
+		return false
+	}
+	if !opts.Until.IsZero() && published.After(opts.Until) {
+		return false
+	}
+	return true
+}
+
+// enclosureExtension возвращает разрешённое расширение вложения по ссылке или по MIME-типу
+func enclosureExtension(u *url.URL, mediaType string, allowed []string) string {
+	candidates := []string{path.Ext(fetcher.FileName(u)), fetcher.ExtensionByType(mediaType)}
+	for _, ext := range candidates {
+		for _, val := range allowed {
+			if ext != "" && ext == val {
+				return ext
+			}
+		}
+	}
+	return ""
+}
+
+// enclosureName возвращает имя файла вложения по заголовку записи. Без заголовка имя берётся
+// из ссылки, но расширение, найденное по MIME-типу, всё равно нужно: иначе файл не пройдёт
+// проверку разрешённых расширений при скачивании. Пустое имя - имя из ссылки подходит как есть
+func enclosureName(title string, u *url.URL, ext string) string {
+	name := sanitizeFileName(title)
+	if urlName := fetcher.FileName(u); name == "" && !strings.HasSuffix(urlName, ext) {
+		if name = sanitizeFileName(urlName); name == "" {
+			name = "enclosure"
+		}
+	}
+	if name != "" && !strings.HasSuffix(name, ext) {
+		name += ext
+	}
+	return name
+}
+
+// sanitizeFileName превращает заголовок записи в безопасное имя файла
+func sanitizeFileName(title string) string {
+	title = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsControl(r):
+			return -1
+		case strings.ContainsRune(`/\:*?"<>|`, r):
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(title))
+	title = strings.Trim(strings.Join(strings.Fields(title), " "), ". ")
+
+	if runes := []rune(title); len(runes) > 100 {
+		title = strings.TrimSpace(string(runes[:100]))
+	}
+	return title
+}
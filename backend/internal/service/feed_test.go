@@ -0,0 +1,31 @@
+package service
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestEnclosureName(t *testing.T) {
+	tests := []struct {
+		title string
+		link  string
+		ext   string
+		want  string
+	}{
+		{"Выпуск 12", "https://h/e/12.mp3", ".mp3", "Выпуск 12.mp3"},
+		{"Report.pdf", "https://h/get?id=5", ".pdf", "Report.pdf"},
+		{"Report.pdf", "https://h/report.pdf", ".pdf", "Report.pdf"},
+		{"Report.PDF", "https://h/get?id=5", ".pdf", "Report.PDF.pdf"},
+		{"Отчёт: итоги/2024", "https://h/r.pdf", ".pdf", "Отчёт_ итоги_2024.pdf"},
+		{"  ..  ", "https://h/report.pdf", ".pdf", ""},
+		{"", "https://h/report.pdf", ".pdf", ""},
+		{"", "https://h/download/42", ".pdf", "42.pdf"},
+		{"", "https://h/", ".pdf", "enclosure.pdf"},
+	}
+	for _, tt := range tests {
+		u, _ := url.Parse(tt.link)
+		if got := enclosureName(tt.title, u, tt.ext); got != tt.want {
+			t.Errorf("%q, %s: получено %q, ожидалось %q", tt.title, tt.link, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/repository"
+	"fmt"
+	"log/slog"
+)
+
+// finalizeIfFinished запускает сборку архива, когда все файлы задачи обработаны.
+// Вызывается после каждого события, которое может завершить задачу: окончания загрузки,
+// окончания разбора страницы или ленты, закрытия задачи. Архив собирается ровно один раз
+func (s *TasksService) finalizeIfFinished(id int64, log *slog.Logger, cfg *config.Config) {
+	task, started, err := s.repo.StartArchiving(id)
+	if err != nil {
+		log.Error("Не удалось проверить готовность задачи", slog.Int64("task_id", id), slog.String("error", err.Error()))
+		return
+	}
+	if started {
+		go s.finalize(task, log, cfg)
+	}
+}
+
+func (s *TasksService) finalize(task repository.Task, log *slog.Logger, cfg *config.Config) {
+	status := finalStatus(task)
+
+	if len(task.LoadedFiles()) == 0 {
+		s.handleTaskErr(fmt.Errorf("ни один файл не удалось скачать, архив не создан"), task.Id, log)
+		return
+	}
+
+	if task.Archive.Mode == ArchiveModeStream {
+		// потоковый архив собирается при скачивании, здесь только проверяются файлы
+		if err := s.prepareStream(task, cfg); err != nil {
+			log.Error("Ошибка при подготовке потокового архива", slog.Int64("task_id", task.Id), slog.String("error", err.Error()))
+			s.handleTaskErr(fmt.Errorf("не удалось подготовить архив: %w", err), task.Id, log)
+			return
+		}
+	} else {
+		log.Info("Сборка архива", slog.Int64("task_id", task.Id), slog.String("format", task.Archive.Format), slog.Int("files", len(task.LoadedFiles())))
+		if _, err := s.MakeArchive(task, task.Archive.Format, cfg); err != nil {
+			log.Error("Ошибка при создании архива", slog.Int64("task_id", task.Id), slog.String("error", err.Error()))
+			s.handleTaskErr(fmt.Errorf("не удалось создать архив: %w", err), task.Id, log)
+			return
+		}
+	}
+
+	if err := s.repo.UpdateTaskStatus(task.Id, status); err != nil {
+		log.Error("Не удалось обновить статус задачи", slog.Int64("task_id", task.Id), slog.String("error", err.Error()))
+		return
+	}
+	log.Info("Архив готов", slog.Int64("task_id", task.Id))
+}
+
+// finalStatus - статус, который задача получит после сборки архива
+func finalStatus(task repository.Task) string {
+	if len(task.Errors) > 0 {
+		return repository.TaskFailed
+	}
+	return repository.TaskCompleted
+}
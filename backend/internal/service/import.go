@@ -0,0 +1,247 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/repository"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log/slog"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	ImportFormatText     = "txt"
+	ImportFormatCSV      = "csv"
+	ImportFormatMetalink = "meta4"
+
+	maxImportSize = 5 << 20
+)
+
+// importRow - строка импортируемого списка. Если err не пуст, строку не удалось разобрать
+type importRow struct {
+	row  int
+	link Link
+	err  error
+}
+
+type metalink struct {
+	XMLName xml.Name `xml:"urn:ietf:params:xml:ns:metalink metalink"`
+	Files   []struct {
+		Name   string `xml:"name,attr"`
+		Hashes []struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"hash"`
+		URLs []struct {
+			Priority int    `xml:"priority,attr"`
+			Value    string `xml:",chardata"`
+		} `xml:"url"`
+	} `xml:"file"`
+}
+
+// ImportLinks разбирает список ссылок (текст, CSV или Metalink 4) и добавляет каждую строку в задачу.
+// Если format пуст, он определяется по расширению файла и содержимому. opts - параметры запроса для всех строк
+func (s *TasksService) ImportLinks(id int64, r io.Reader, fileName, format string, opts repository.RequestOptions, log *slog.Logger, cfg *config.Config) ([]LinkResult, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxImportSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл: %w", err)
+	}
+	if len(data) > maxImportSize {
+		return nil, fmt.Errorf("файл больше %d МБ", maxImportSize>>20)
+	}
+	if _, err := s.repo.GetTask(id); err != nil {
+		return nil, fmt.Errorf("не удалось получить задачу: %w", err)
+	}
+
+	if format == "" {
+		format = detectImportFormat(fileName, data)
+	}
+	var rows []importRow
+	switch format {
+	case ImportFormatText:
+		rows = parseTextList(data)
+	case ImportFormatCSV:
+		rows, err = parseCSVList(data)
+	case ImportFormatMetalink:
+		rows, err = parseMetalink(data)
+	default:
+		return nil, fmt.Errorf("неизвестный формат списка: %s, поддерживаются txt, csv, meta4", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("в файле не найдено ни одной ссылки")
+	}
+
+	results := make([]LinkResult, len(rows))
+	for i, row := range rows {
+		var result LinkResult
+		if row.err == nil {
+			link := row.link
+			link.Options = opts
+			result, row.err = s.appendLink(id, link, log, cfg)
+		}
+		results[i] = newLinkResult(result, row.err)
+		results[i].Row, results[i].Link = row.row, RedactLink(row.link.URL)
+	}
+	log.Info("Список ссылок импортирован", slog.Int64("task_id", id), slog.String("format", format), slog.Int("rows", len(rows)))
+	return results, nil
+}
+
+func detectImportFormat(fileName string, data []byte) string {
+	switch strings.ToLower(path.Ext(fileName)) {
+	case ".meta4", ".metalink":
+		return ImportFormatMetalink
+	case ".csv":
+		return ImportFormatCSV
+	case ".txt", ".list":
+		return ImportFormatText
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+		return ImportFormatMetalink
+	}
+	return ImportFormatText
+}
+
+// parseTextList - по одной ссылке в строке, пустые строки и строки с # пропускаются
+func parseTextList(data []byte) []importRow {
+	var rows []importRow
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rows = append(rows, importRow{row: n, link: Link{URL: line}})
+	}
+	return rows
+}
+
+// parseCSVList разбирает CSV с колонками url, filename, checksum, folder. Заголовок необязателен:
+// без него колонки идут в этом порядке. Разделитель - запятая или точка с запятой
+func parseCSVList(data []byte) ([]importRow, error) {
+	data = bytes.TrimPrefix(data, []byte("\ufeff"))
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	columns := map[string]int{"url": 0, "filename": 1, "checksum": 2, "folder": 3}
+	var rows []importRow
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			parseErr, ok := err.(*csv.ParseError)
+			if !ok {
+				return nil, fmt.Errorf("не удалось разобрать CSV: %w", err)
+			}
+			rows = append(rows, importRow{row: parseErr.StartLine, err: fmt.Errorf("некорректная строка CSV: %w", parseErr.Err)})
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		if first && isCSVHeader(record) {
+			columns = make(map[string]int)
+			for i, name := range record {
+				columns[strings.ToLower(strings.TrimSpace(name))] = i
+			}
+			if _, ok := columns["url"]; !ok {
+				return nil, fmt.Errorf("в заголовке CSV нет колонки url")
+			}
+			continue
+		}
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := importRow{row: line, link: Link{URL: field("url"), Name: field("filename"), Folder: field("folder")}}
+		if row.link.URL == "" {
+			if strings.Join(record, "") == "" {
+				continue
+			}
+			row.err = fmt.Errorf("не указана ссылка")
+		}
+		row.link.Checksum = field("checksum")
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func isCSVHeader(record []string) bool {
+	for _, name := range record {
+		if strings.EqualFold(strings.TrimSpace(name), "url") {
+			return true
+		}
+	}
+	return false
+}
+
+// parseMetalink разбирает Metalink 4 (RFC 5854). Ссылки файла сортируются по приоритету:
+// первая становится основной, остальные - зеркалами
+func parseMetalink(data []byte) ([]importRow, error) {
+	var doc metalink
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать Metalink: %w", err)
+	}
+
+	var rows []importRow
+	for i, file := range doc.Files {
+		row := importRow{row: i + 1, link: Link{Name: path.Base(strings.TrimSpace(file.Name))}}
+		if row.link.Name == "." || row.link.Name == "/" {
+			row.link.Name = ""
+		}
+
+		urls := file.URLs
+		sort.SliceStable(urls, func(a, b int) bool {
+			return metalinkPriority(urls[a].Priority) < metalinkPriority(urls[b].Priority)
+		})
+		for _, u := range urls {
+			if value := strings.TrimSpace(u.Value); value != "" {
+				if row.link.URL == "" {
+					row.link.URL = value
+				} else {
+					row.link.Mirrors = append(row.link.Mirrors, value)
+				}
+			}
+		}
+		if row.link.URL == "" {
+			row.err = fmt.Errorf("у файла %q нет ссылок", file.Name)
+		}
+
+		// берём самый стойкий из поддерживаемых хэшей
+		for _, algo := range []string{"sha-512", "sha-256", "md5"} {
+			for _, hash := range file.Hashes {
+				if row.link.Checksum == "" && strings.EqualFold(hash.Type, algo) {
+					row.link.Checksum = algo + ":" + strings.TrimSpace(hash.Value)
+				}
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// metalinkPriority - ссылки без приоритета идут после ссылок с приоритетом
+func metalinkPriority(priority int) int {
+	if priority <= 0 {
+		return 1 << 30
+	}
+	return priority
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// importCase - строка списка в удобном для сравнения виде; err - подстрока ошибки
+type importCase struct {
+	row      int
+	url      string
+	name     string
+	folder   string
+	checksum string
+	mirrors  []string
+	err      string
+}
+
+func flattenRows(rows []importRow) []importCase {
+	out := make([]importCase, len(rows))
+	for i, row := range rows {
+		out[i] = importCase{
+			row: row.row, url: row.link.URL, name: row.link.Name, folder: row.link.Folder,
+			checksum: row.link.Checksum, mirrors: row.link.Mirrors,
+		}
+		if row.err != nil {
+			out[i].err = row.err.Error()
+		}
+	}
+	return out
+}
+
+func matchRows(t *testing.T, name string, got []importRow, want []importCase) {
+	t.Helper()
+	flat := flattenRows(got)
+	if len(flat) != len(want) {
+		t.Errorf("%s: получено %d строк %+v, ожидалось %d", name, len(flat), flat, len(want))
+		return
+	}
+	for i := range want {
+		g, w := flat[i], want[i]
+		if w.err != "" && !strings.Contains(g.err, w.err) || w.err == "" && g.err != "" {
+			t.Errorf("%s, строка %d: ошибка %q, ожидалось %q", name, i, g.err, w.err)
+		}
+		g.err, w.err = "", ""
+		if !reflect.DeepEqual(g, w) {
+			t.Errorf("%s, строка %d: получено %+v, ожидалось %+v", name, i, g, w)
+		}
+	}
+}
+
+func TestParseTextList(t *testing.T) {
+	data := "# список\nhttps://h/a.pdf\n\n  https://h/b.pdf  \r\n#https://h/skip.pdf\nhttps://h/c.pdf"
+	matchRows(t, "txt", parseTextList([]byte(data)), []importCase{
+		{row: 2, url: "https://h/a.pdf"},
+		{row: 4, url: "https://h/b.pdf"},
+		{row: 6, url: "https://h/c.pdf"},
+	})
+}
+
+func TestParseCSVList(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want []importCase
+	}{
+		{
+			"без заголовка",
+			"https://h/a.pdf,a.pdf,sha256:00ff,docs\nhttps://h/b.pdf\n",
+			[]importCase{
+				{row: 1, url: "https://h/a.pdf", name: "a.pdf", checksum: "sha256:00ff", folder: "docs"},
+				{row: 2, url: "https://h/b.pdf"},
+			},
+		},
+		{
+			"заголовок в другом порядке",
+			"Folder, URL ,filename\nreports,https://h/a.pdf,Отчёт.pdf\n",
+			[]importCase{{row: 2, url: "https://h/a.pdf", name: "Отчёт.pdf", folder: "reports"}},
+		},
+		{
+			"точка с запятой и BOM",
+			"\ufeffurl;filename\r\n\"https://h/a.pdf?x=1,2\";a.pdf\r\n",
+			[]importCase{{row: 2, url: "https://h/a.pdf?x=1,2", name: "a.pdf"}},
+		},
+		{
+			"пустые строки и строка без ссылки",
+			"url,filename\n,\n,b.pdf\nhttps://h/c.pdf,c.pdf\n",
+			[]importCase{
+				{row: 3, name: "b.pdf", err: "не указана ссылка"},
+				{row: 4, url: "https://h/c.pdf", name: "c.pdf"},
+			},
+		},
+		{
+			"битая кавычка не прерывает разбор",
+			"https://h/a.pdf,\"a.pdf\nhttps://h/b.pdf\n",
+			[]importCase{{row: 1, err: "некорректная строка CSV"}},
+		},
+	}
+	for _, tt := range tests {
+		rows, err := parseCSVList([]byte(tt.data))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		matchRows(t, tt.name, rows, tt.want)
+	}
+}
+
+func TestParseMetalink(t *testing.T) {
+	data := `<?xml version="1.0" encoding="UTF-8"?>
+<metalink xmlns="urn:ietf:params:xml:ns:metalink">
+  <file name="dir/../../etc/report.pdf">
+    <hash type="md5">d41d8cd98f00b204e9800998ecf8427e</hash>
+    <hash type="sha-256">e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855</hash>
+    <url priority="2">https://mirror.example.com/report.pdf</url>
+    <url>https://slow.example.com/report.pdf</url>
+    <url priority="1">https://main.example.com/report.pdf</url>
+  </file>
+  <file name="/">
+    <hash type="md5">d41d8cd98f00b204e9800998ecf8427e</hash>
+    <url> https://h/b.pdf </url>
+  </file>
+  <file name="empty.pdf"></file>
+</metalink>`
+	rows, err := parseMetalink([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	matchRows(t, "meta4", rows, []importCase{
+		{
+			row: 1, url: "https://main.example.com/report.pdf", name: "report.pdf",
+			checksum: "sha-256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+			mirrors:  []string{"https://mirror.example.com/report.pdf", "https://slow.example.com/report.pdf"},
+		},
+		{row: 2, url: "https://h/b.pdf", checksum: "md5:d41d8cd98f00b204e9800998ecf8427e"},
+		{row: 3, name: "empty.pdf", err: "нет ссылок"},
+	})
+
+	for _, bad := range []string{
+		"<metalink><file name=\"a\"/></metalink>",
+		"<metalink xmlns=\"urn:ietf:params:xml:ns:metalink\"><file>",
+	} {
+		if _, err := parseMetalink([]byte(bad)); err == nil {
+			t.Errorf("%q: ожидалась ошибка", bad)
+		}
+	}
+}
+
+func TestDetectImportFormat(t *testing.T) {
+	tests := []struct {
+		fileName string
+		data     string
+		want     string
+	}{
+		{"list.META4", "https://h/a.pdf", ImportFormatMetalink},
+		{"list.metalink", "", ImportFormatMetalink},
+		{"list.csv", "<xml>", ImportFormatCSV},
+		{"list.txt", "<xml>", ImportFormatText},
+		{"links.list", "", ImportFormatText},
+		{"upload", "  \n<?xml version=\"1.0\"?><metalink/>", ImportFormatMetalink},
+		{"upload", "https://h/a.pdf,a.pdf", ImportFormatText},
+		{"", "https://h/a.pdf", ImportFormatText},
+	}
+	for _, tt := range tests {
+		if got := detectImportFormat(tt.fileName, []byte(tt.data)); got != tt.want {
+			t.Errorf("%q: получено %s, ожидалось %s", tt.fileName, got, tt.want)
+		}
+	}
+}
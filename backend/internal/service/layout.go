@@ -0,0 +1,93 @@
+package service
+
+import (
+	"backend/internal/repository"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Раскладка файлов по папкам архива
+const (
+	LayoutFlat = "flat"
+	LayoutHost = "host"
+	LayoutType = "type"
+
+	// otherFolder - папка файлов, для которых не удалось определить хост или тип
+	otherFolder = "other"
+)
+
+// archiveNames выбирает уникальные пути скачанных файлов в архиве: индекс файла в задаче -> путь.
+// Имена отчёта заняты заранее, файл с таким же именем получит суффикс
+func archiveNames(task repository.Task) map[int]string {
+	used := map[string]bool{manifestName: true, reportName: true, indexName: true}
+	names := make(map[int]string)
+	for i, file := range task.Files {
+		if file.Status == repository.FileLoaded {
+			names[i] = uniqueName(used, path.Join(entryFolder(task.Archive.Layout, file), file.Name))
+		}
+	}
+	return names
+}
+
+// entryFolder возвращает папку файла в архиве: заданную клиентом или по раскладке архива
+func entryFolder(layout string, file repository.File) string {
+	if file.Folder != "" {
+		return file.Folder
+	}
+	switch layout {
+	case LayoutHost:
+		u, err := url.Parse(file.Link)
+		if err != nil {
+			return otherFolder
+		}
+		if host := u.Hostname(); host != "" {
+			return host
+		}
+		// у data: и подобных ссылок хоста нет, такие файлы складываются по схеме
+		if u.Scheme != "" {
+			return u.Scheme
+		}
+		return otherFolder
+	case LayoutType:
+		mediaType, _, _ := strings.Cut(fileMediaType(file.Name, file.ContentType), "/")
+		if mediaType == "" {
+			return otherFolder
+		}
+		return mediaType
+	default:
+		return ""
+	}
+}
+
+// cleanFolder приводит папку, заданную клиентом, к относительному пути без . и ..,
+// чтобы файл нельзя было вынести за пределы архива при распаковке
+func cleanFolder(folder string) string {
+	folder = strings.ReplaceAll(strings.TrimSpace(folder), `\`, "/")
+	if folder == "" {
+		return ""
+	}
+	return strings.TrimPrefix(path.Clean("/"+folder), "/")
+}
+
+// cleanFileName приводит имя файла, заданное клиентом или взятое из ссылки, к безопасному виду
+// так же, как заголовки записей ленты (см. sanitizeFileName), но не теряет расширение при обрезке:
+// по расширению файл проверяется при скачивании
+func cleanFileName(name string) string {
+	ext := path.Ext(name)
+	stem := sanitizeFileName(strings.TrimSuffix(name, ext))
+	if ext = sanitizeFileName(ext); stem == "" || ext == "" {
+		return sanitizeFileName(name)
+	}
+	return stem + "." + ext
+}
+
+// entryTime - время изменения записи: Last-Modified из ответа сервера или, если его нет,
+// archiveEpoch. Время раньше 1980 года в zip не записать, оно тоже заменяется на archiveEpoch
+func entryTime(lastModified time.Time) time.Time {
+	if lastModified.Before(archiveEpoch) {
+		return archiveEpoch
+	}
+	return lastModified.UTC()
+}
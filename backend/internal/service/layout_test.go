@@ -0,0 +1,98 @@
+package service
+
+import (
+	"backend/internal/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCleanFileName(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"../../etc/passwd.txt", "_.._etc_passwd.txt"},
+		{`..\..\boot.ini.txt`, `_.._boot.ini.txt`},
+		{"a/b:c*d?.pdf", "a_b_c_d_.pdf"},
+		{"  отчёт \t 2024 .pdf ", "отчёт 2024.pdf"},
+		{"line\nbreak\x00.pdf", "linebreak.pdf"},
+		{strings.Repeat("я", 150) + ".pdf", strings.Repeat("я", 100) + ".pdf"},
+		{"..", ""},
+		{"", ""},
+		{"noext", "noext"},
+	}
+	for _, tt := range tests {
+		if got := cleanFileName(tt.in); got != tt.want {
+			t.Errorf("cleanFileName(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCleanFolder(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"docs", "docs"},
+		{"docs/2024/", "docs/2024"},
+		{"../docs/./texts", "docs/texts"},
+		{"/etc", "etc"},
+		{`..\..\windows`, "windows"},
+		{"../..", ""},
+	}
+	for _, tt := range tests {
+		if got := cleanFolder(tt.in); got != tt.want {
+			t.Errorf("cleanFolder(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestArchiveNames(t *testing.T) {
+	loaded := func(link, name, contentType, folder string) repository.File {
+		return repository.File{Link: link, Name: name, ContentType: contentType, Folder: folder, Status: repository.FileLoaded}
+	}
+	files := []repository.File{
+		loaded("https://a.example/x.pdf", "x.pdf", "application/pdf", ""),
+		loaded("https://b.example/x.pdf", "x.pdf", "application/pdf", ""),
+		loaded("https://a.example/pic.png", "pic.png", "", ""),
+		loaded("data:text/plain,hi", "data.txt", "text/plain", ""),
+		loaded("https://a.example/m.pdf", "MANIFEST.json", "", ""),
+		loaded("https://a.example/y.pdf", "y.pdf", "", "custom"),
+		{Link: "https://a.example/failed.pdf", Status: repository.FileFailed},
+	}
+	tests := []struct {
+		layout string
+		want   []string
+	}{
+		{LayoutFlat, []string{"x.pdf", "x (2).pdf", "pic.png", "data.txt", "MANIFEST (2).json", "custom/y.pdf"}},
+		{LayoutHost, []string{"a.example/x.pdf", "b.example/x.pdf", "a.example/pic.png", "data/data.txt", "a.example/MANIFEST.json", "custom/y.pdf"}},
+		{LayoutType, []string{"application/x.pdf", "application/x (2).pdf", "image/pic.png", "text/data.txt", "application/MANIFEST.json", "custom/y.pdf"}},
+	}
+	for _, tt := range tests {
+		names := archiveNames(repository.Task{Files: files, Archive: repository.ArchiveOptions{Layout: tt.layout}})
+		if len(names) != len(tt.want) {
+			t.Errorf("%s: %d имён, ожидалось %d: %v", tt.layout, len(names), len(tt.want), names)
+			continue
+		}
+		for i, want := range tt.want {
+			if names[i] != want {
+				t.Errorf("%s: файл %d = %q, ожидалось %q", tt.layout, i, names[i], want)
+			}
+		}
+	}
+}
+
+func TestEntryTime(t *testing.T) {
+	if got := entryTime(time.Time{}); !got.Equal(archiveEpoch) {
+		t.Errorf("нулевое время: %v", got)
+	}
+	if got := entryTime(time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)); !got.Equal(archiveEpoch) {
+		t.Errorf("время до 1980: %v", got)
+	}
+	moscow := time.FixedZone("MSK", 3*60*60)
+	modified := time.Date(2021, 3, 4, 8, 6, 8, 0, moscow)
+	if got := entryTime(modified); got != modified.UTC() {
+		t.Errorf("entryTime = %v, ожидалось %v", got, modified.UTC())
+	}
+}
@@ -0,0 +1,143 @@
+package service
+
+import (
+	"backend/internal/fetcher"
+	"backend/internal/repository"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	LinkModeFile    = "file"
+	LinkModeExtract = "extract"
+	LinkModeFeed    = "feed"
+)
+
+// Link - ссылка на файл вместе с параметрами запроса и зеркалами.
+// В режиме extract ссылка указывает на HTML-страницу, из которой извлекаются файлы,
+// в режиме feed - на RSS/Atom-ленту с вложениями.
+type Link struct {
+	URL     string
+	Mirrors []string
+	Options repository.RequestOptions
+	// Name - имя файла в архиве. Если не задано, берётся из ссылки
+	Name string
+	// Folder - папка файла в архиве, для страниц и лент - папка всех найденных файлов
+	Folder string
+	// Checksum - ожидаемая контрольная сумма файла в виде algo:hex
+	Checksum string
+	// Preflight - проверить доступность, тип и размер файла до добавления в задачу
+	Preflight bool
+	// Expand - URL является шаблоном с диапазонами и перечислениями, см. ExpandTemplate
+	Expand  bool
+	Mode    string
+	Extract ExtractOptions
+	Feed    FeedOptions
+}
+
+// LinkResult - результат приёма одной ссылки в пакетном запросе
+type LinkResult struct {
+	// Row - номер строки (или файла в Metalink) при импорте списка
+	Row      int    `json:"row,omitempty"`
+	Link     string `json:"link"`
+	Accepted bool   `json:"accepted"`
+	// Merged - ссылка оказалась повтором и объединена с файлом DuplicateOf, новый файл не создан
+	Merged bool `json:"merged,omitempty"`
+	// DuplicateOf - номер файла задачи, с которым объединена повторная ссылка
+	DuplicateOf *int `json:"duplicate_of,omitempty"`
+	// ContentType, Size и Warnings - результат предварительной проверки, если она выполнялась
+	ContentType string   `json:"content_type,omitempty"`
+	Size        int64    `json:"size,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+func newLinkResult(result LinkResult, err error) LinkResult {
+	result.Accepted = err == nil
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// optionsFor возвращает параметры запроса для ссылки target, найденной на странице или в ленте origin.
+// Учётные данные уходят только туда же, откуда получена страница: та же схема и тот же хост с портом
+func optionsFor(opts repository.RequestOptions, origin, target *url.URL) repository.RequestOptions {
+	if strings.EqualFold(origin.Scheme, target.Scheme) && strings.EqualFold(origin.Host, target.Host) {
+		return opts
+	}
+	return opts.WithoutCredentials()
+}
+
+// RedactLink скрывает пароль из ссылки перед записью в лог или задачу
+func RedactLink(link string) string {
+	u, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return u.Redacted()
+}
+
+func redactLinks(links []string) []string {
+	if len(links) == 0 {
+		return nil
+	}
+	out := make([]string, len(links))
+	for i, link := range links {
+		out[i] = RedactLink(link)
+	}
+	return out
+}
+
+func validateOptions(opts repository.RequestOptions) error {
+	if opts.Proxy == "" {
+		return nil
+	}
+	proxy, err := url.Parse(opts.Proxy)
+	if err != nil {
+		return fmt.Errorf("некорректный адрес прокси: %w", err)
+	}
+	switch proxy.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return nil
+	}
+	return fmt.Errorf("неподдерживаемая схема прокси: %s", proxy.Scheme)
+}
+
+func newFetchRequest(u *url.URL, opts repository.RequestOptions) *fetcher.Request {
+	header := make(http.Header)
+	for name, value := range opts.Headers {
+		header.Set(name, value)
+	}
+	if opts.BearerToken != "" {
+		header.Set("Authorization", "Bearer "+opts.BearerToken)
+	}
+	if opts.UserAgent != "" {
+		header.Set("User-Agent", opts.UserAgent)
+	}
+	if len(opts.Cookies) > 0 {
+		names := make([]string, 0, len(opts.Cookies))
+		for name := range opts.Cookies {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		cookies := make([]string, 0, len(names))
+		for _, name := range names {
+			cookies = append(cookies, (&http.Cookie{Name: name, Value: opts.Cookies[name]}).String())
+		}
+		header.Set("Cookie", strings.Join(cookies, "; "))
+	}
+
+	req := &fetcher.Request{URL: u, Header: header}
+	if opts.Proxy != "" {
+		req.Proxy, _ = url.Parse(opts.Proxy)
+	}
+	if opts.BearerToken == "" {
+		req.Username, req.Password = opts.Username, opts.Password
+	}
+	return req
+}
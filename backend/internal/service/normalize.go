@@ -0,0 +1,132 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+const (
+	DedupReject = "reject"
+	DedupMerge  = "merge"
+	DedupOff    = "off"
+)
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// NormalizeURL приводит ссылку к каноническому виду, не меняя адресуемый ресурс:
+// схема и хост в нижнем регистре, IDN в punycode, без порта по умолчанию и фрагмента.
+// Путь и параметры запроса остаются как есть: для S3, FTP и файлов a//b и a/../b - другие
+// объекты, поэтому сегменты . и .. и кодирование учитываются только в dedupKey
+func NormalizeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return "", fmt.Errorf("некорректная ссылка: %w", err)
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Opaque != "" || u.Scheme == "data" {
+		// data: и другие непрозрачные ссылки сравниваются как есть
+		return u.String(), nil
+	}
+
+	host, port := u.Hostname(), u.Port()
+	host = strings.TrimSuffix(strings.ToLower(host), ".")
+	if ascii, err := idna.Lookup.ToASCII(host); err == nil {
+		host = ascii
+	}
+	if port == defaultPorts[u.Scheme] {
+		port = ""
+	}
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	if port != "" {
+		host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+	}
+	u.Host = host
+	u.Fragment, u.RawFragment = "", ""
+
+	if u.Path == "" && (u.Scheme == "http" || u.Scheme == "https") {
+		u.Path, u.RawPath = "/", ""
+	}
+	u.ForceQuery = false
+	return u.String(), nil
+}
+
+// dedupKey - ключ для поиска одинаковых ссылок в задаче: нормализованная ссылка без логина
+// и пароля, с одинаковым процентным кодированием и отсортированными параметрами запроса.
+// Для http(s) путь дополнительно очищается от сегментов . и .., повторных / и завершающего /
+// (кроме корня). Ссылка для скачивания при этом не меняется: сервер может различать /dir и /dir/,
+// а пути S3, FTP и файлов сравниваются как есть
+func dedupKey(normalized string) string {
+	u, err := url.Parse(normalized)
+	if err != nil || u.Opaque != "" || u.Scheme == "data" {
+		return normalized
+	}
+	u.User = nil
+	escaped := normalizeEscapes(u.EscapedPath())
+	if (u.Scheme == "http" || u.Scheme == "https") && escaped != "" {
+		escaped = path.Clean(escaped)
+	}
+	u.RawPath = escaped
+	if u.Path, err = url.PathUnescape(escaped); err != nil {
+		return normalized
+	}
+	if u.RawQuery != "" {
+		params := strings.Split(normalizeEscapes(u.RawQuery), "&")
+		sort.Strings(params)
+		u.RawQuery = strings.Join(params, "&")
+	}
+	return u.String()
+}
+
+// normalizeEscapes раскодирует незарезервированные символы (RFC 3986, 2.3) и переводит
+// остальные %XX в верхний регистр
+func normalizeEscapes(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+2 >= len(s) || !isHex(s[i+1]) || !isHex(s[i+2]) {
+			b.WriteByte(s[i])
+			continue
+		}
+		c := unhex(s[i+1])<<4 | unhex(s[i+2])
+		if isUnreserved(c) {
+			b.WriteByte(c)
+		} else {
+			b.WriteString(strings.ToUpper(s[i : i+3]))
+		}
+		i += 2
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) >= 0
+}
+
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
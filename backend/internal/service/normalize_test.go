@@ -0,0 +1,99 @@
+package service
+
+import "testing"
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"HTTP://Example.COM/a.pdf", "http://example.com/a.pdf"},
+		{"https://example.com:443/a.pdf", "https://example.com/a.pdf"},
+		{"http://example.com:8080/a.pdf", "http://example.com:8080/a.pdf"},
+		{"ftp://files.example.com:21/pub/a.pdf", "ftp://files.example.com/pub/a.pdf"},
+		{"https://example.com", "https://example.com/"},
+		{"https://example.com/a.pdf#page=2", "https://example.com/a.pdf"},
+		{"https://example.com/docs/", "https://example.com/docs/"},
+		// путь и параметры скачиваются как есть, очистка пути - только в dedupKey
+		{"https://example.com/docs/./old/../a.pdf", "https://example.com/docs/./old/../a.pdf"},
+		{"https://h/a//b.pdf", "https://h/a//b.pdf"},
+		{"https://example.com/%7euser/%2fa%2Fb.pdf", "https://example.com/%7euser/%2fa%2Fb.pdf"},
+		{"https://example.com/a.pdf?q=%61%2f", "https://example.com/a.pdf?q=%61%2f"},
+		{"S3://Bucket/a//b.pdf", "s3://bucket/a//b.pdf"},
+		{"s3://bucket/logs/../x.pdf", "s3://bucket/logs/../x.pdf"},
+		{"s3://bucket/dir/", "s3://bucket/dir/"},
+		{"FTP://Files.Example.com/pub//../a.pdf", "ftp://files.example.com/pub//../a.pdf"},
+		{"file:///srv/data/./a.pdf", "file:///srv/data/./a.pdf"},
+		{"https://пример.рф/файл.pdf", "https://xn--e1afmkfd.xn--p1ai/%D1%84%D0%B0%D0%B9%D0%BB.pdf"},
+		{"https://example.com./a.pdf", "https://example.com/a.pdf"},
+		{"https://[::1]:443/a.pdf", "https://[::1]/a.pdf"},
+		{"  https://example.com/a.pdf  ", "https://example.com/a.pdf"},
+		{"DATA:text/plain,Hello", "data:text/plain,Hello"},
+	}
+	for _, tt := range tests {
+		got, err := NormalizeURL(tt.in)
+		if err != nil {
+			t.Errorf("NormalizeURL(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("NormalizeURL(%q) = %q, ожидалось %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"https://example.com/a.pdf?x=1&y=2", "https://example.com/a.pdf?y=2&x=1", true},
+		{"https://user:pw@example.com/a.pdf", "https://example.com/a.pdf", true},
+		{"https://example.com/docs/", "https://example.com/docs", true},
+		{"https://example.com/", "https://example.com", true},
+		{"HTTPS://EXAMPLE.com:443/a.pdf#x", "https://example.com/a.pdf", true},
+		{"https://example.com/a%2Fb/", "https://example.com/a%2fb", true},
+		{"https://example.com/docs/./old/../a.pdf", "https://example.com/docs/a.pdf", true},
+		{"https://h/a//b.pdf", "https://h/a/b.pdf", true},
+		{"https://example.com/%7euser/a.pdf", "https://example.com/~user/a.pdf", true},
+		{"https://example.com/a.pdf?q=%61%2f", "https://example.com/a.pdf?q=a%2F", true},
+		{"https://example.com/a.pdf?x=1", "https://example.com/a.pdf?x=2", false},
+		{"s3://bucket/a//b.pdf", "s3://bucket/a/b.pdf", false},
+		{"s3://bucket/logs/../x.pdf", "s3://bucket/x.pdf", false},
+		{"s3://bucket/dir/", "s3://bucket/dir", false},
+		{"s3://bucket/%7e/a.pdf", "S3://BUCKET/~/a.pdf", true},
+		{"ftp://h/pub/../a.pdf", "ftp://h/a.pdf", false},
+		{"ftp://h/pub//a.pdf", "ftp://h/pub/a.pdf", false},
+		{"ftp://h:21/pub/a.pdf", "ftp://h/pub/a.pdf", true},
+		{"file:///srv/a/../b.pdf", "file:///srv/b.pdf", false},
+		{"file:///srv/./b.pdf", "file:///srv/b.pdf", false},
+		{"https://example.com/a.pdf", "http://example.com/a.pdf", false},
+		{"https://example.com/A.pdf", "https://example.com/a.pdf", false},
+		{"data:,a", "data:,b", false},
+	}
+	for _, tt := range tests {
+		a, err := NormalizeURL(tt.a)
+		if err != nil {
+			t.Fatalf("NormalizeURL(%q): %v", tt.a, err)
+		}
+		b, err := NormalizeURL(tt.b)
+		if err != nil {
+			t.Fatalf("NormalizeURL(%q): %v", tt.b, err)
+		}
+		if got := dedupKey(a) == dedupKey(b); got != tt.same {
+			t.Errorf("dedupKey(%q) = %q, dedupKey(%q) = %q, совпадение %v, ожидалось %v", tt.a, dedupKey(a), tt.b, dedupKey(b), got, tt.same)
+		}
+	}
+}
+
+func TestNormalizeKeepsTrailingSlash(t *testing.T) {
+	got, err := NormalizeURL("https://example.com/list/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "https://example.com/list/" {
+		t.Errorf("ссылка для скачивания потеряла слэш: %q", got)
+	}
+	if key := dedupKey(got); key != "https://example.com/list" {
+		t.Errorf("dedupKey = %q", key)
+	}
+}
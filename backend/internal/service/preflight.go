@@ -0,0 +1,144 @@
+package service
+
+import (
+	"backend/internal/config"
+	"context"
+	"fmt"
+	"mime"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// типы, которые серверы отдают для любых файлов: по ним нельзя судить о содержимом
+var genericContentTypes = map[string]bool{
+	"":                           true,
+	"application/octet-stream":   true,
+	"binary/octet-stream":        true,
+	"application/binary":         true,
+	"application/download":       true,
+	"application/force-download": true,
+	"application/x-download":     true,
+}
+
+const maxValidateConcurrency = 4
+
+// PreflightResult - результат предварительной проверки ссылки
+type PreflightResult struct {
+	Link        string   `json:"link"`
+	OK          bool     `json:"ok"`
+	Source      string   `json:"source,omitempty"`
+	ContentType string   `json:"content_type,omitempty"`
+	Size        int64    `json:"size,omitempty"`
+	FinalURL    string   `json:"final_url,omitempty"`
+	Error       string   `json:"error,omitempty"`
+	Warnings    []string `json:"warnings,omitempty"`
+}
+
+// PreflightError - ссылка не прошла предварительную проверку при добавлении в задачу
+type PreflightError struct {
+	Result PreflightResult
+}
+
+func (e *PreflightError) Error() string {
+	return "предварительная проверка не пройдена: " + e.Result.Error
+}
+
+// ValidateLinks проверяет ссылки, ничего не добавляя в задачи
+func (s *TasksService) ValidateLinks(links []Link, cfg *config.Config) []PreflightResult {
+	results := make([]PreflightResult, len(links))
+	sem := make(chan struct{}, maxValidateConcurrency)
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			link, _ = s.rewriteLink(link)
+			err := s.validateLink(&link)
+			if err == nil && link.Expand {
+				err = fmt.Errorf("шаблоны ссылок не проверяются, используйте dry_run")
+			}
+			if err == nil && link.Mode != "" && link.Mode != LinkModeFile {
+				err = fmt.Errorf("проверяются только ссылки на файлы")
+			}
+			if err != nil {
+				results[i] = PreflightResult{Link: RedactLink(link.URL), Error: err.Error()}
+				return
+			}
+			results[i] = s.preflight(link, cfg)
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+// preflight проверяет основную ссылку, а если она не прошла - зеркала по порядку.
+// Результат успешен, если проверку прошла хотя бы одна ссылка
+func (s *TasksService) preflight(link Link, cfg *config.Config) PreflightResult {
+	var errs []string
+	for _, candidate := range append([]string{link.URL}, link.Mirrors...) {
+		result, err := s.probe(candidate, link, cfg)
+		if err == nil {
+			result.Link = RedactLink(link.URL)
+			return result
+		}
+		errs = append(errs, fmt.Sprintf("%s: %s", RedactLink(candidate), err))
+	}
+	return PreflightResult{Link: RedactLink(link.URL), Error: strings.Join(errs, "; ")}
+}
+
+func (s *TasksService) probe(candidate string, link Link, cfg *config.Config) (PreflightResult, error) {
+	u, err := url.Parse(candidate)
+	if err != nil {
+		return PreflightResult{}, fmt.Errorf("некорректная ссылка: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Preflight.Timeout)
+	defer cancel()
+	resp, err := s.fetchers.Probe(ctx, newFetchRequest(u, link.Options))
+	if err != nil {
+		return PreflightResult{}, fmt.Errorf("ссылка недоступна: %w", err)
+	}
+
+	result := PreflightResult{
+		OK:          true,
+		Source:      RedactLink(candidate),
+		ContentType: resp.ContentType,
+		FinalURL:    resp.FinalURL,
+	}
+	if resp.ContentLength >= 0 {
+		result.Size = resp.ContentLength
+	} else {
+		result.Warnings = append(result.Warnings, "сервер не сообщил размер файла")
+	}
+	if cfg.Preflight.MaxSize > 0 && resp.ContentLength > cfg.Preflight.MaxSize {
+		return result, fmt.Errorf("размер файла %d байт больше допустимого %d", resp.ContentLength, cfg.Preflight.MaxSize)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.ContentType)
+	if genericContentTypes[strings.ToLower(mediaType)] {
+		result.Warnings = append(result.Warnings, "сервер не сообщил тип содержимого")
+	} else if !allowedContentType(mediaType, cfg) {
+		return result, fmt.Errorf("тип содержимого %s не соответствует разрешённым форматам %s", mediaType, cfg.AllowedExtensions)
+	}
+	// то же правило выбора имени, что и при скачивании: ссылка, прошедшая проверку, не должна
+	// затем отклоняться из-за расширения
+	if _, ok := fileNameFor(link, u, resp, cfg); !ok {
+		return result, errFileFormat(cfg)
+	}
+	return result, nil
+}
+
+// allowedContentType проверяет, что MIME-тип соответствует одному из разрешённых расширений
+func allowedContentType(mediaType string, cfg *config.Config) bool {
+	for _, ext := range strings.Split(cfg.AllowedExtensions, ",") {
+		expected, _, _ := mime.ParseMediaType(mime.TypeByExtension(strings.TrimSpace(ext)))
+		if expected != "" && strings.EqualFold(expected, mediaType) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/fetcher"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestPreflightMatchesDownload проверяет, что предварительная проверка принимает и отклоняет
+// по расширению те же ссылки, что и скачивание
+func TestPreflightMatchesDownload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if disposition := r.URL.Query().Get("disposition"); disposition != "" {
+			w.Header().Set("Content-Disposition", disposition)
+		}
+		w.Header().Set("Content-Type", r.URL.Query().Get("type"))
+		io.WriteString(w, "%PDF-1.4")
+	}))
+	defer server.Close()
+
+	t.Chdir(t.TempDir())
+	cfg := &config.Config{AllowedExtensions: ".pdf", Preflight: config.Preflight{Timeout: 5 * time.Second}}
+	fetchers, err := fetcher.NewRegistry(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewTasksService(nil, fetchers, &Rewriter{}, nil)
+
+	tests := []struct {
+		path string
+		name string
+		ok   bool
+	}{
+		{"/get?id=5&type=application/pdf", "", true},
+		{"/get?id=5&type=application/octet-stream", "", false},
+		{"/get?id=5&type=application/octet-stream&disposition=attachment%3Bfilename%3Dr.pdf", "", true},
+		{"/report.pdf?type=application/octet-stream", "", true},
+		{"/get?id=5&type=application/pdf", "report.zip", false},
+		{"/get?id=5&type=application/octet-stream", "report.pdf", true},
+	}
+	for i, tt := range tests {
+		link := Link{URL: server.URL + tt.path, Name: tt.name}
+		preflight := s.preflight(link, cfg)
+		if preflight.OK != tt.ok {
+			t.Errorf("%s, %q: проверка = %+v, ожидалось %v", tt.path, tt.name, preflight, tt.ok)
+		}
+		_, err := s.download(1, i, link.URL, link, cfg)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s, %q: скачивание: %v, ожидалось %v", tt.path, tt.name, err, tt.ok)
+		}
+		if !tt.ok && !strings.Contains(preflight.Error, "неверный формат файла") {
+			t.Errorf("%s: ошибка проверки %q", tt.path, preflight.Error)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"backend/internal/repository"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Отчёт о задаче, который кладётся в каждый архив: по нему видно, какие ссылки не попали в архив и почему
+const (
+	manifestName = "MANIFEST.json"
+	reportName   = "REPORT.txt"
+	indexName    = "index.html"
+)
+
+// manifest - машиночитаемый отчёт о задаче. Files перечисляет все ссылки задачи, включая неудачные
+type manifest struct {
+	TaskID     int64          `json:"task_id"`
+	Status     string         `json:"status"`
+	CreatedAt  time.Time      `json:"created_at"`
+	FinishedAt time.Time      `json:"finished_at,omitzero"`
+	Files      []manifestFile `json:"files"`
+	Errors     []string       `json:"errors,omitempty"`
+}
+
+// manifestFile - итог обработки одной ссылки. ArchiveName пуст, если файл в архив не попал
+type manifestFile struct {
+	Link             string    `json:"link"`
+	OriginalLink     string    `json:"original_link,omitempty"`
+	Mirrors          []string  `json:"mirrors,omitempty"`
+	Aliases          []string  `json:"aliases,omitempty"`
+	Status           string    `json:"status"`
+	ArchiveName      string    `json:"archive_name,omitempty"`
+	Source           string    `json:"source,omitempty"`
+	FinalURL         string    `json:"final_url,omitempty"`
+	Size             int64     `json:"size,omitempty"`
+	LastModified     time.Time `json:"last_modified,omitzero"`
+	ContentType      string    `json:"content_type,omitempty"`
+	Checksum         string    `json:"checksum,omitempty"`
+	ExpectedChecksum string    `json:"expected_checksum,omitempty"`
+	ActualChecksum   string    `json:"actual_checksum,omitempty"`
+	Error            string    `json:"error,omitempty"`
+}
+
+// newManifest составляет отчёт о задаче. names - имена скачанных файлов в архиве по их индексу в задаче
+func newManifest(task repository.Task, names map[int]string) manifest {
+	m := manifest{
+		TaskID:     task.Id,
+		Status:     finalStatus(task),
+		CreatedAt:  task.CreatedAt,
+		FinishedAt: task.FinishedAt,
+		Files:      make([]manifestFile, len(task.Files)),
+	}
+	for _, taskErr := range task.Errors {
+		m.Errors = append(m.Errors, strings.TrimSpace(taskErr))
+	}
+	for i, file := range task.Files {
+		m.Files[i] = manifestFile{
+			Link:             file.Link,
+			OriginalLink:     file.OriginalLink,
+			Mirrors:          file.Mirrors,
+			Aliases:          file.Aliases,
+			Status:           file.Status,
+			ArchiveName:      names[i],
+			Source:           file.Source,
+			FinalURL:         file.FinalURL,
+			Size:             file.Size,
+			LastModified:     file.LastModified,
+			ContentType:      file.ContentType,
+			Checksum:         file.Checksum,
+			ExpectedChecksum: file.ExpectedChecksum,
+			ActualChecksum:   file.ActualChecksum,
+			Error:            file.Error,
+		}
+	}
+	return m
+}
+
+var reportFuncs = map[string]any{
+	"time": formatReportTime,
+	"inc":  func(i int) int { return i + 1 },
+}
+
+var reportTemplate = template.Must(template.New(reportName).Funcs(reportFuncs).Parse(
+	`Задача {{.TaskID}}
+Статус: {{.Status}}
+Создана: {{time .CreatedAt}}
+Завершена: {{time .FinishedAt}}
+{{range $i, $f := .Files}}
+{{inc $i}}. {{$f.Link}}
+   Статус: {{$f.Status}}
+{{- if $f.OriginalLink}}
+   Исходная ссылка: {{$f.OriginalLink}}{{end}}
+{{- if $f.ArchiveName}}
+   Имя в архиве: {{$f.ArchiveName}}{{end}}
+{{- if $f.Source}}
+   Скачан с: {{$f.Source}}{{end}}
+{{- if $f.Size}}
+   Размер: {{$f.Size}} байт{{end}}
+{{- if $f.ContentType}}
+   Тип: {{$f.ContentType}}{{end}}
+{{- if not $f.LastModified.IsZero}}
+   Изменён: {{time $f.LastModified}}{{end}}
+{{- if $f.Checksum}}
+   Контрольная сумма: {{$f.Checksum}}{{end}}
+{{- if and $f.ActualChecksum (ne $f.ActualChecksum $f.Checksum)}}
+   Контрольная сумма: {{$f.ActualChecksum}}{{end}}
+{{- if $f.Error}}
+   Ошибка: {{$f.Error}}{{end}}
+{{end}}
+{{- if .Errors}}
+Ошибки задачи:
+{{range .Errors}}- {{.}}
+{{end}}{{end}}`))
+
+var indexTemplate = htmltemplate.Must(htmltemplate.New(indexName).Funcs(reportFuncs).Parse(
+	`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Задача {{.TaskID}}</title>
+</head>
+<body>
+<h1>Задача {{.TaskID}}</h1>
+<p>Статус: {{.Status}}<br>Создана: {{time .CreatedAt}}<br>Завершена: {{time .FinishedAt}}</p>
+<table border="1" cellpadding="4">
+<tr><th>Ссылка</th><th>Статус</th><th>Файл</th><th>Размер</th><th>Тип</th><th>Контрольная сумма</th><th>Ошибка</th></tr>
+{{range .Files}}<tr><td>{{.Link}}</td><td>{{.Status}}</td><td>{{if .ArchiveName}}<a href="{{.ArchiveName}}">{{.ArchiveName}}</a>{{end}}</td><td>{{if .Size}}{{.Size}}{{end}}</td><td>{{.ContentType}}</td><td>{{.Checksum}}</td><td>{{.Error}}</td></tr>
+{{end}}</table>
+{{if .Errors}}<h2>Ошибки задачи</h2>
+<ul>
+{{range .Errors}}<li>{{.}}</li>
+{{end}}</ul>
+{{end}}</body>
+</html>
+`))
+
+// formatReportTime выводит время в отчёте, нулевое время - прочерком
+func formatReportTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Format(time.RFC3339)
+}
+
+// reportEntries возвращает MANIFEST.json, REPORT.txt и index.html для архива задачи. Отчёт кладётся
+// без сжатия: он невелик, а размер потокового zip без сжатия известен заранее
+func reportEntries(task repository.Task, names map[int]string) ([]entrySource, error) {
+	m := newManifest(task, names)
+
+	manifestJSON, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при составлении манифеста: %w", err)
+	}
+	var report, index bytes.Buffer
+	if err := reportTemplate.Execute(&report, m); err != nil {
+		return nil, fmt.Errorf("ошибка при составлении отчёта: %w", err)
+	}
+	if err := indexTemplate.Execute(&index, m); err != nil {
+		return nil, fmt.Errorf("ошибка при составлении отчёта: %w", err)
+	}
+
+	entries := []entrySource{
+		reportEntry(manifestName, "application/json", append(manifestJSON, '\n')),
+		reportEntry(reportName, "text/plain; charset=utf-8", report.Bytes()),
+		reportEntry(indexName, "text/html; charset=utf-8", index.Bytes()),
+	}
+	return entries, nil
+}
+
+func reportEntry(name, contentType string, data []byte) entrySource {
+	return entrySource{
+		name:        name,
+		contentType: contentType,
+		size:        int64(len(data)),
+		modTime:     archiveEpoch,
+		content:     bytes.NewReader(data),
+		data:        bytes.NewReader(data),
+		stored:      true,
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/repository"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+)
+
+// RewriteRule - правило переписывания ссылки из файла REWRITE_RULES_FILE.
+// Replace - новая ссылка целиком, в ней доступны группы из Match ($1, ${name}).
+// Headers добавляются к запросу, если клиент не передал заголовок с тем же именем
+type RewriteRule struct {
+	Name    string            `json:"name"`
+	Match   string            `json:"match"`
+	Replace string            `json:"replace"`
+	Headers map[string]string `json:"headers,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Rewriter применяет к ссылке первое подходящее правило
+type Rewriter struct {
+	rules []RewriteRule
+}
+
+// RewriteResult - результат применения правил к ссылке
+type RewriteResult struct {
+	Original  string            `json:"original"`
+	Rewritten string            `json:"rewritten"`
+	Rule      string            `json:"rule,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+}
+
+// NewRewriter читает и компилирует правила. Без файла правил ссылки не переписываются
+func NewRewriter(cfg config.Rewrite) (*Rewriter, error) {
+	if cfg.RulesFile == "" {
+		return &Rewriter{}, nil
+	}
+	data, err := os.ReadFile(cfg.RulesFile)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать правила переписывания ссылок: %w", err)
+	}
+
+	var rules []RewriteRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать правила переписывания ссылок: %w", err)
+	}
+	for i := range rules {
+		if rules[i].Name == "" {
+			rules[i].Name = fmt.Sprintf("rule-%d", i+1)
+		}
+		if rules[i].Match == "" || rules[i].Replace == "" {
+			return nil, fmt.Errorf("правило %s: match и replace обязательны", rules[i].Name)
+		}
+		if rules[i].re, err = regexp.Compile(rules[i].Match); err != nil {
+			return nil, fmt.Errorf("правило %s: некорректное регулярное выражение: %w", rules[i].Name, err)
+		}
+	}
+	return &Rewriter{rules: rules}, nil
+}
+
+// Rewrite возвращает ссылку после применения первого подходящего правила
+func (r *Rewriter) Rewrite(link string) RewriteResult {
+	result := RewriteResult{Original: link, Rewritten: link}
+	for _, rule := range r.rules {
+		match := rule.re.FindStringSubmatchIndex(link)
+		if match == nil {
+			continue
+		}
+		result.Rewritten = string(rule.re.ExpandString(nil, rule.Replace, link, match))
+		result.Rule = rule.Name
+		if len(rule.Headers) > 0 {
+			result.Headers = make(map[string]string, len(rule.Headers))
+			for name, value := range rule.Headers {
+				result.Headers[name] = string(rule.re.ExpandString(nil, value, link, match))
+			}
+		}
+		return result
+	}
+	return result
+}
+
+// rewriteLink применяет правила к основной ссылке и зеркалам. Заголовки правила
+// основной ссылки дополняют параметры запроса
+func (s *TasksService) rewriteLink(link Link) (Link, RewriteResult) {
+	result := s.rewriter.Rewrite(link.URL)
+	link.URL = result.Rewritten
+
+	if len(result.Headers) > 0 {
+		headers := make(map[string]string, len(link.Options.Headers)+len(result.Headers))
+		for name, value := range result.Headers {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+		for name, value := range link.Options.Headers {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+		link.Options.Headers = headers
+	}
+
+	if len(link.Mirrors) > 0 {
+		mirrors := make([]string, len(link.Mirrors))
+		for i, mirror := range link.Mirrors {
+			mirrors[i] = s.rewriter.Rewrite(mirror).Rewritten
+		}
+		link.Mirrors = mirrors
+	}
+	return link, result
+}
+
+// TestRewrite показывает, как будет переписана ссылка. Значения чувствительных заголовков скрываются
+func (s *TasksService) TestRewrite(link string) RewriteResult {
+	result := s.rewriter.Rewrite(link)
+	result.Original = RedactLink(result.Original)
+	result.Rewritten = RedactLink(result.Rewritten)
+	if len(result.Headers) > 0 {
+		result.Headers = repository.RequestOptions{Headers: result.Headers}.Redacted().Headers
+	}
+	return result
+}
+
+// originalLink - исходная ссылка для записи в файл задачи, если правило её изменило
+func originalLink(result RewriteResult) string {
+	if result.Rule == "" || result.Original == result.Rewritten {
+		return ""
+	}
+	return RedactLink(result.Original)
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/repository"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestRewriter(t *testing.T, rules string) (*Rewriter, error) {
+	t.Helper()
+	file := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(file, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return NewRewriter(config.Rewrite{RulesFile: file})
+}
+
+const testRules = `[
+  {
+    "name": "viewer",
+    "match": "^(https://files\\.example\\.com)/view/(?P<id>[^/?]+)",
+    "replace": "${1}/download/${id}",
+    "headers": {"referer": "$1/", "Authorization": "Bearer rule-token"}
+  },
+  {
+    "match": "^https://drive\\.example\\.com/file/d/([^/]+)/.*$",
+    "replace": "https://drive.example.com/uc?export=download&id=$1"
+  }
+]`
+
+func TestRewriter(t *testing.T) {
+	r, err := newTestRewriter(t, testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		link, want, rule string
+	}{
+		// replace задаёт новую ссылку целиком, остаток исходной ссылки не переносится
+		{"https://files.example.com/view/abc?x=1", "https://files.example.com/download/abc", "viewer"},
+		{"https://drive.example.com/file/d/XyZ/view?usp=sharing", "https://drive.example.com/uc?export=download&id=XyZ", "rule-2"},
+		{"https://other.example.com/view/abc", "https://other.example.com/view/abc", ""},
+	}
+	for _, tt := range tests {
+		got := r.Rewrite(tt.link)
+		if got.Rewritten != tt.want || got.Rule != tt.rule || got.Original != tt.link {
+			t.Errorf("Rewrite(%q) = %+v, ожидалось %q по правилу %q", tt.link, got, tt.want, tt.rule)
+		}
+	}
+	if got := r.Rewrite(tests[0].link).Headers["referer"]; got != "https://files.example.com/" {
+		t.Errorf("заголовок с группой: %q", got)
+	}
+}
+
+func TestRewriteLinkKeepsClientHeaders(t *testing.T) {
+	r, err := newTestRewriter(t, testRules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &TasksService{rewriter: r}
+	link, result := s.rewriteLink(Link{
+		URL:     "https://files.example.com/view/abc",
+		Mirrors: []string{"https://files.example.com/view/def", "https://mirror.example.com/abc"},
+		Options: repository.RequestOptions{Headers: map[string]string{"authorization": "Bearer client-token"}},
+	})
+	if link.URL != "https://files.example.com/download/abc" || result.Rule != "viewer" {
+		t.Errorf("ссылка = %q, правило %q", link.URL, result.Rule)
+	}
+	if got := link.Options.Headers["Authorization"]; got != "Bearer client-token" {
+		t.Errorf("заголовок клиента заменён правилом: %q", got)
+	}
+	if got := link.Options.Headers["Referer"]; got != "https://files.example.com/" {
+		t.Errorf("заголовок правила не добавлен: %q", got)
+	}
+	want := []string{"https://files.example.com/download/def", "https://mirror.example.com/abc"}
+	if strings.Join(link.Mirrors, " ") != strings.Join(want, " ") {
+		t.Errorf("зеркала = %v, ожидалось %v", link.Mirrors, want)
+	}
+	if got := originalLink(result); got != "https://files.example.com/view/abc" {
+		t.Errorf("originalLink = %q", got)
+	}
+
+	if tested := s.TestRewrite("https://files.example.com/view/abc"); tested.Headers["Authorization"] != "***" {
+		t.Errorf("TestRewrite не скрыл чувствительный заголовок: %v", tested.Headers)
+	}
+}
+
+func TestNewRewriterInvalid(t *testing.T) {
+	for _, rules := range []string{
+		`[{"match": "(", "replace": "x"}]`,
+		`[{"match": "x"}]`,
+		`{"match": "x"}`,
+	} {
+		if _, err := newTestRewriter(t, rules); err == nil {
+			t.Errorf("%s: ожидалась ошибка", rules)
+		}
+	}
+	r, err := NewRewriter(config.Rewrite{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.Rewrite("https://h/a.pdf"); got.Rewritten != "https://h/a.pdf" || got.Rule != "" {
+		t.Errorf("без правил ссылка изменилась: %+v", got)
+	}
+}
@@ -2,24 +2,33 @@ package service
 
 import (
 	"backend/internal/config"
+	"backend/internal/fetcher"
 	"backend/internal/repository"
+	"io"
 	"log/slog"
 )
 
 type Tasks interface {
-	CreateTask() (int64, error)
-	AppendLink(id int64, link string, log *slog.Logger, cfg *config.Config) error
-	GetArchivePath(id int64) (string, error)
+	CreateTask(archive repository.ArchiveOptions, cfg *config.Config) (int64, error)
+	CreateTaskWithLinks(links []Link, archive repository.ArchiveOptions, log *slog.Logger, cfg *config.Config) (int64, []LinkResult, error)
+	AppendLink(id int64, link Link, log *slog.Logger, cfg *config.Config) (LinkResult, error)
+	AppendLinks(id int64, links []Link, log *slog.Logger, cfg *config.Config) []LinkResult
+	ImportLinks(id int64, r io.Reader, fileName, format string, opts repository.RequestOptions, log *slog.Logger, cfg *config.Config) ([]LinkResult, error)
+	TestRewrite(link string) RewriteResult
+	ValidateLinks(links []Link, cfg *config.Config) []PreflightResult
+	PreviewTemplate(id int64, template string, cfg *config.Config) (*TemplatePreview, error)
+	GetArchive(id int64, format string, cfg *config.Config) (*Archive, error)
+	GetSignature(id int64, format string, cfg *config.Config) (*Signature, error)
+	SigningKey() ([]byte, error)
 	GetTask(id int64) (*repository.Task, error)
-	MakeArchive(task repository.Task) (repository.Task, error)
 }
 
 type Service struct {
 	Tasks Tasks
 }
 
-func NewService(repositories *repository.Repositories) *Service {
+func NewService(repositories *repository.Repositories, fetchers *fetcher.Registry, rewriter *Rewriter, signer *Signer) *Service {
 	return &Service{
-		Tasks: NewTasksService(repositories.Tasks),
+		Tasks: NewTasksService(repositories.Tasks, fetchers, rewriter, signer),
 	}
 }
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"backend/internal/config"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrSigningDisabled - ключ подписи не задан, архивы не подписываются
+var ErrSigningDisabled = errors.New("подпись архивов не настроена")
+
+// ErrSignatureNotFound - у архива задачи нет подписи: он ещё не собран или это зашифрованный потоковый архив
+var ErrSignatureNotFound = errors.New("подпись архива не найдена")
+
+// signatureExt - расширение файла отсоединённой подписи
+const signatureExt = ".sig"
+
+// Signer подписывает собранные архивы ключом ed25519 из SIGNING_KEY_FILE. Сообщение для ed25519 -
+// не сам архив, а 32 байта его SHA-256, поэтому архив не нужно читать повторно, а проверяющий
+// должен сначала посчитать дайджест (см. VerifyArchive). Без ключа архивы не подписываются
+type Signer struct {
+	key ed25519.PrivateKey
+}
+
+// NewSigner читает закрытый ключ ed25519 в PEM (PKCS#8), например созданный
+// openssl genpkey -algorithm ed25519
+func NewSigner(cfg config.Signing) (*Signer, error) {
+	if cfg.KeyFile == "" {
+		return &Signer{}, nil
+	}
+	data, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать ключ подписи: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("ключ подписи должен быть в PEM с заголовком PRIVATE KEY")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разобрать ключ подписи: %w", err)
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("ключ подписи должен быть ed25519, получен %T", parsed)
+	}
+	return &Signer{key: key}, nil
+}
+
+func (s *Signer) Enabled() bool {
+	return s != nil && s.key != nil
+}
+
+// Sign подписывает архив по его контрольной сумме sha256:hex. Без ключа возвращает nil
+func (s *Signer) Sign(checksum string) ([]byte, error) {
+	if !s.Enabled() || checksum == "" {
+		return nil, nil
+	}
+	digest, err := checksumDigest(checksum)
+	if err != nil {
+		return nil, err
+	}
+	return ed25519.Sign(s.key, digest), nil
+}
+
+// PublicKeyPEM возвращает открытый ключ для проверки подписей в PEM (PUBLIC KEY)
+func (s *Signer) PublicKeyPEM() ([]byte, error) {
+	if !s.Enabled() {
+		return nil, ErrSigningDisabled
+	}
+	der, err := x509.MarshalPKIXPublicKey(s.key.Public())
+	if err != nil {
+		return nil, fmt.Errorf("не удалось сохранить открытый ключ: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// Signature - отсоединённая подпись архива задачи для скачивания
+type Signature struct {
+	FileName string
+	Data     []byte
+}
+
+// GetSignature возвращает подпись архива задачи в формате format, пустой формат - формат задачи.
+// В режиме file архив в другом формате собирается, если его ещё не запрашивали. Потоковый архив
+// подписывается только в формате задачи: в других форматах его сумма заранее не считается
+func (s *TasksService) GetSignature(id int64, format string, cfg *config.Config) (*Signature, error) {
+	if !s.signer.Enabled() {
+		return nil, ErrSigningDisabled
+	}
+	task, err := s.repo.GetTask(id)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить задачу: %w", err)
+	}
+	if !task.ArchiveReady() {
+		return nil, fmt.Errorf("%w: задача %d", ErrArchiveNotFound, id)
+	}
+	if format == "" {
+		format = task.Archive.Format
+	}
+	if _, ok := archiveFormats[format]; !ok {
+		return nil, fmt.Errorf("%w: неизвестный формат %s", ErrArchiveFormat, format)
+	}
+
+	info := *task.ArchiveInfo
+	if format != task.Archive.Format {
+		if task.Archive.Mode == ArchiveModeStream {
+			return nil, fmt.Errorf("%w: потоковый архив задачи %d подписывается только в формате %s", ErrSignatureNotFound, id, task.Archive.Format)
+		}
+		if _, err := s.GetArchive(id, format, cfg); err != nil {
+			return nil, err
+		}
+		if task, err = s.repo.GetTask(id); err != nil {
+			return nil, fmt.Errorf("не удалось получить задачу: %w", err)
+		}
+		info = task.Archives[format]
+	}
+	if info.Signature == nil {
+		return nil, fmt.Errorf("%w: задача %d", ErrSignatureNotFound, id)
+	}
+	return &Signature{
+		FileName: "archive" + fileFormat(format, task.Archive.Encrypted).Ext + signatureExt,
+		Data:     info.Signature,
+	}, nil
+}
+
+// SigningKey возвращает открытый ключ подписи архивов в PEM
+func (s *TasksService) SigningKey() ([]byte, error) {
+	return s.signer.PublicKeyPEM()
+}
+
+// checksumDigest переводит контрольную сумму sha256:hex в байты
+func checksumDigest(checksum string) ([]byte, error) {
+	hexSum, ok := strings.CutPrefix(checksum, "sha256:")
+	if !ok {
+		return nil, fmt.Errorf("подписать можно только SHA-256 архива, получено %s", checksum)
+	}
+	digest, err := hex.DecodeString(hexSum)
+	if err != nil || len(digest) != sha256.Size {
+		return nil, fmt.Errorf("некорректная контрольная сумма архива: %s", checksum)
+	}
+	return digest, nil
+}
+
+// ParsePublicKey читает открытый ключ ed25519 в PEM, который отдаёт /.well-known/archiver-signing-key
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, fmt.Errorf("открытый ключ должен быть в PEM с заголовком PUBLIC KEY")
+	}
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось разобрать открытый ключ: %w", err)
+	}
+	key, ok := parsed.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("открытый ключ должен быть ed25519, получен %T", parsed)
+	}
+	return key, nil
+}
+
+// VerifyArchive проверяет отсоединённую подпись архива: считает SHA-256 содержимого
+// и сверяет с ним подпись открытым ключом
+func VerifyArchive(publicKey ed25519.PublicKey, archive io.Reader, signature []byte) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, archive); err != nil {
+		return fmt.Errorf("ошибка при чтении архива: %w", err)
+	}
+	if !ed25519.Verify(publicKey, h.Sum(nil), signature) {
+		return fmt.Errorf("подпись архива недействительна")
+	}
+	return nil
+}
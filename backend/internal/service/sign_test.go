@@ -0,0 +1,164 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/repository"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeKeyFile(t *testing.T, key any) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newTestSigner(t *testing.T) *Signer {
+	t.Helper()
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := NewSigner(config.Signing{KeyFile: writeKeyFile(t, key)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+func checksumOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestSignAndVerifyArchive(t *testing.T) {
+	signer := newTestSigner(t)
+	archive := []byte("archive bytes")
+	signature, err := signer.Sign(checksumOf(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(signature) != ed25519.SignatureSize {
+		t.Fatalf("подпись %d байт", len(signature))
+	}
+
+	keyPEM, err := signer.PublicKeyPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicKey, err := ParsePublicKey(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyArchive(publicKey, bytes.NewReader(archive), signature); err != nil {
+		t.Errorf("подпись не прошла проверку: %v", err)
+	}
+
+	// подписывается дайджест SHA-256, а не сам архив
+	digest := sha256.Sum256(archive)
+	if !ed25519.Verify(publicKey, digest[:], signature) {
+		t.Error("подпись не является подписью 32 байт SHA-256 архива")
+	}
+
+	tampered := bytes.Clone(archive)
+	tampered[0] ^= 1
+	if err := VerifyArchive(publicKey, bytes.NewReader(tampered), signature); err == nil {
+		t.Error("изменённый архив прошёл проверку")
+	}
+	badSignature := bytes.Clone(signature)
+	badSignature[10] ^= 1
+	if err := VerifyArchive(publicKey, bytes.NewReader(archive), badSignature); err == nil {
+		t.Error("изменённая подпись прошла проверку")
+	}
+	otherKey, _ := newTestSigner(t).PublicKeyPEM()
+	otherPublic, _ := ParsePublicKey(otherKey)
+	if err := VerifyArchive(otherPublic, bytes.NewReader(archive), signature); err == nil {
+		t.Error("подпись прошла проверку чужим ключом")
+	}
+}
+
+func TestSignerDisabledAndInvalidKeys(t *testing.T) {
+	disabled, err := NewSigner(config.Signing{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if signature, err := disabled.Sign(checksumOf([]byte("x"))); signature != nil || err != nil {
+		t.Errorf("без ключа: %x, %v", signature, err)
+	}
+	if _, err := disabled.PublicKeyPEM(); !errors.Is(err, ErrSigningDisabled) {
+		t.Errorf("PublicKeyPEM без ключа: %v", err)
+	}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSigner(config.Signing{KeyFile: writeKeyFile(t, rsaKey)}); err == nil {
+		t.Error("ключ RSA принят")
+	}
+	if _, err := NewSigner(config.Signing{KeyFile: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("отсутствующий файл ключа принят")
+	}
+	if _, err := newTestSigner(t).Sign("md5:00000000000000000000000000000000"); err == nil {
+		t.Error("подписана сумма не SHA-256")
+	}
+}
+
+func TestGetSignaturePerFormat(t *testing.T) {
+	s, task, cfg := newArchiveTestTask(t, repository.ArchiveOptions{Format: ArchiveZip, Compression: CompressionAuto, CompressionLevel: 6})
+	s.signer = newTestSigner(t)
+	keyPEM, _ := s.SigningKey()
+	publicKey, err := ParsePublicKey(keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.MakeArchive(task, ArchiveZip, cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, format := range []string{"", ArchiveTarGz} {
+		signature, err := s.GetSignature(task.Id, format, cfg)
+		if err != nil {
+			t.Fatalf("GetSignature(%q): %v", format, err)
+		}
+		archive, err := s.GetArchive(task.Id, format, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signature.FileName != archive.FileName+signatureExt {
+			t.Errorf("имя подписи %s для архива %s", signature.FileName, archive.FileName)
+		}
+		data, err := os.ReadFile(archive.Path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifyArchive(publicKey, bytes.NewReader(data), signature.Data); err != nil {
+			t.Errorf("%s: %v", archive.FileName, err)
+		}
+	}
+
+	saved, _ := s.repo.GetTask(task.Id)
+	if info := saved.Archives[ArchiveTarGz]; info.Checksum == "" || info.Signature == nil {
+		t.Errorf("у архива tar.gz не сохранены сумма и подпись: %+v", info)
+	}
+	if _, err := s.GetSignature(task.Id, "rar", cfg); !errors.Is(err, ErrArchiveFormat) {
+		t.Errorf("неизвестный формат: %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/repository"
+	"fmt"
+	"io"
+	"os"
+)
+
+// prepareStream сохраняет сведения о потоковом архиве вместо его сборки: имена и размеры файлов,
+// размер и контрольную сумму архива. Незашифрованный архив воспроизводим, поэтому его размер
+// и сумма считаются одним прогоном сборки без записи на диск. Зашифрованный архив при каждом
+// скачивании другой: для него известен только размер, и то лишь когда файлы не сжимаются
+func (s *TasksService) prepareStream(task repository.Task, cfg *config.Config) error {
+	enc, err := s.encryption(task, task.Archive.Format)
+	if err != nil {
+		return err
+	}
+	var size int64
+	var checksum string
+	if enc == nil {
+		var counter countingWriter
+		sums := newChecksummer("")
+		if _, err := writeArchive(io.MultiWriter(&counter, sums.Writer()), task, task.Archive.Format, cfg, nil, false); err != nil {
+			return err
+		}
+		size, checksum = int64(counter), sums.Sum()
+	} else if size, err = streamSize(task, task.Archive.Format, cfg, enc); err != nil {
+		return err
+	}
+
+	signature, err := s.signer.Sign(checksum)
+	if err != nil {
+		return err
+	}
+	info := repository.ArchiveInfo{Format: task.Archive.Format, Checksum: checksum, Signature: signature}
+	names := archiveNames(task)
+	reports, err := reportEntries(task, names)
+	if err != nil {
+		return err
+	}
+	for _, src := range reports {
+		info.Entries = append(info.Entries, repository.ArchiveEntry{Name: src.name, Size: src.size})
+		info.OriginalSize += src.size
+	}
+	for i, file := range task.Files {
+		name, ok := names[i]
+		if !ok {
+			continue
+		}
+		stat, err := os.Stat(file.Path)
+		if err != nil {
+			return fmt.Errorf("ошибка при чтении файла %s: %w", file.Path, err)
+		}
+		info.Entries = append(info.Entries, repository.ArchiveEntry{Name: name, Size: stat.Size()})
+		info.OriginalSize += stat.Size()
+	}
+	if size >= 0 {
+		info.Size = size
+		info.Ratio = compressionRatio(size, info.OriginalSize)
+	}
+	return s.repo.SaveArchive(task.Id, info)
+}
+
+// streamSize считает размер потокового архива, прогоняя его запись без содержимого файлов.
+// Размер известен заранее для tar и для zip, все файлы которого кладутся без сжатия, иначе -1.
+// У tar, зашифрованного age, размер не считается: это потребовало бы лишнего шифрования
+func streamSize(task repository.Task, format string, cfg *config.Config, enc *archiveEncryption) (int64, error) {
+	switch format {
+	case ArchiveTar:
+		if enc != nil {
+			return -1, nil
+		}
+	case ArchiveZip:
+		stored, err := allStored(task, cfg)
+		if err != nil || !stored {
+			return -1, err
+		}
+	default:
+		return -1, nil
+	}
+
+	var counter countingWriter
+	if _, err := writeArchive(&counter, task, format, cfg, enc, true); err != nil {
+		return -1, err
+	}
+	return int64(counter), nil
+}
+
+// allStored проверяет, что политика сжатия задачи кладёт в zip без сжатия все файлы.
+// Отчёт о задаче всегда кладётся без сжатия и здесь не проверяется
+func allStored(task repository.Task, cfg *config.Config) (bool, error) {
+	policy := newCompressionPolicy(task.Archive.Compression, task.Archive.CompressionLevel, cfg)
+	for _, taskFile := range task.LoadedFiles() {
+		file, err := os.Open(taskFile.Path)
+		if err != nil {
+			return false, fmt.Errorf("ошибка при открытии файла: %w", err)
+		}
+		store, err := policy.Store(file, taskFile.Name, taskFile.ContentType)
+		file.Close()
+		if err != nil || !store {
+			return false, err
+		}
+	}
+	return true, nil
+}
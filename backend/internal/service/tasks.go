@@ -1,228 +1,404 @@
 package service
 
 import (
-	"archive/zip"
 	"backend/internal/config"
+	"backend/internal/fetcher"
 	"backend/internal/repository"
+	"errors"
 	"fmt"
-	"io"
 	"log/slog"
-	"net/http"
-	"os"
+	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 )
 
+var errFileLimit = errors.New("превышено максимальное количество файлов в задаче")
+
+var errTaskSealed = errors.New("задача закрыта для новых ссылок")
+
+// ErrDuplicateLink - ссылка уже есть в задаче, а DEDUP_MODE=reject
+var ErrDuplicateLink = errors.New("ссылка уже добавлена в задачу")
+
 type TasksService struct {
 	semaphore chan struct{}
 	repo      repository.Tasks
+	fetchers  *fetcher.Registry
+	rewriter  *Rewriter
+	signer    *Signer
+	// mu защищает проверку лимита файлов и добавление файла в задачу
+	mu sync.Mutex
+	// archiveLocks - блокировки сборки архивов по запросу, ключ "id/формат"
+	archiveLocks sync.Map
+	secrets      *secretBox
 }
 
-func NewTasksService(repo repository.Tasks) *TasksService {
+func NewTasksService(repo repository.Tasks, fetchers *fetcher.Registry, rewriter *Rewriter, signer *Signer) *TasksService {
 	return &TasksService{
 		semaphore: make(chan struct{}, 3),
 		repo:      repo,
+		fetchers:  fetchers,
+		rewriter:  rewriter,
+		signer:    signer,
+		secrets:   newSecretBox(),
 	}
 }
 
-func (s *TasksService) CreateTask() (int64, error) {
+func (s *TasksService) CreateTask(archive repository.ArchiveOptions, cfg *config.Config) (int64, error) {
+	archive, err := normalizeArchiveOptions(archive, cfg)
+	if err != nil {
+		return -1, err
+	}
 	if s.repo.CountActiveTasks() == 3 {
 		return -1, fmt.Errorf("сервер в данный момент занят")
 	}
-	return s.repo.CreateTask()
+	return s.repo.CreateTask(s.sealPassphrase(archive))
 }
 
-func (s *TasksService) AppendLink(id int64, link string, log *slog.Logger, cfg *config.Config) error {
-	if link == "" {
-		return fmt.Errorf("ссылка не может быть пустой")
+// AppendLink добавляет ссылку в задачу. Результат содержит номер файла, с которым объединена
+// повторная ссылка, и итог предварительной проверки
+func (s *TasksService) AppendLink(id int64, link Link, log *slog.Logger, cfg *config.Config) (LinkResult, error) {
+	result, err := s.appendLink(id, link, log, cfg)
+	result.Link = RedactLink(link.URL)
+	return newLinkResult(result, err), err
+}
+
+// appendLink добавляет ссылку. В результате заполняются DuplicateOf и поля предварительной проверки
+func (s *TasksService) appendLink(id int64, link Link, log *slog.Logger, cfg *config.Config) (LinkResult, error) {
+	if link.URL == "" {
+		return LinkResult{}, fmt.Errorf("ссылка не может быть пустой")
+	}
+	if link.Expand {
+		return LinkResult{}, s.appendTemplate(id, link, log, cfg)
+	}
+	pending, err := s.prepareLink(link)
+	if err != nil {
+		return pending.result, err
+	}
+	if err := s.preflightLink(&pending, cfg); err != nil {
+		return pending.result, err
 	}
-	if !(strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://")) {
-		return fmt.Errorf("ссылка должна начинаться с http:// или https://")
+	return s.commitLink(id, pending, log, cfg)
+}
+
+// pendingLink - проверенная ссылка, которую осталось добавить в задачу
+type pendingLink struct {
+	link      Link
+	submitted string
+	// file заполняется только для ссылок режима file
+	file   repository.File
+	result LinkResult
+}
+
+// prepareLink переписывает и проверяет ссылку, а для режима file - собирает файл задачи.
+// Задача при этом не меняется
+func (s *TasksService) prepareLink(link Link) (pendingLink, error) {
+	pending := pendingLink{submitted: RedactLink(link.URL)}
+	link, rewrite := s.rewriteLink(link)
+	if err := s.validateLink(&link); err != nil {
+		return pending, err
+	}
+	pending.link = link
+
+	switch link.Mode {
+	case "", LinkModeFile:
+	case LinkModeExtract, LinkModeFeed:
+		return pending, nil
+	default:
+		return pending, fmt.Errorf("неизвестный режим ссылки: %s", link.Mode)
 	}
 
-	err := s.repo.AppendLink(id, link)
+	pending.file = repository.File{
+		Name:             link.Name,
+		Folder:           link.Folder,
+		Link:             RedactLink(link.URL),
+		Mirrors:          redactLinks(link.Mirrors),
+		Options:          link.Options.Redacted(),
+		Status:           repository.FileQueued,
+		ExpectedChecksum: link.Checksum,
+		OriginalLink:     originalLink(rewrite),
+		RewriteRule:      rewrite.Rule,
+		Key:              dedupKey(link.URL),
+	}
+	return pending, nil
+}
+
+// preflightLink выполняет предварительную проверку файла, если она включена для ссылки или в конфиге
+func (s *TasksService) preflightLink(pending *pendingLink, cfg *config.Config) error {
+	link := pending.link
+	if link.Mode != "" && link.Mode != LinkModeFile || !link.Preflight && !cfg.Preflight.Enabled {
+		return nil
+	}
+	preflight := s.preflight(link, cfg)
+	if !preflight.OK {
+		return &PreflightError{Result: preflight}
+	}
+	pending.file.ContentType, pending.file.Size = preflight.ContentType, preflight.Size
+	pending.result.ContentType, pending.result.Size, pending.result.Warnings = preflight.ContentType, preflight.Size, preflight.Warnings
+	return nil
+}
+
+// commitLink добавляет подготовленную ссылку в задачу и запускает скачивание или разбор
+func (s *TasksService) commitLink(id int64, pending pendingLink, log *slog.Logger, cfg *config.Config) (LinkResult, error) {
+	result, link := pending.result, pending.link
+	switch link.Mode {
+	case LinkModeExtract:
+		return result, s.startExtract(id, link, log, cfg)
+	case LinkModeFeed:
+		return result, s.startFeed(id, link, log, cfg)
+	}
+
+	idx, duplicate, err := s.appendFile(id, pending.file, pending.submitted, cfg)
 	if err != nil {
-		return fmt.Errorf("не удалось добавить ссылку: %w", err)
+		return result, err
+	}
+	if duplicate {
+		log.Info("Повторная ссылка объединена с файлом задачи", slog.Int64("task_id", id), slog.Int("file", idx))
+		result.DuplicateOf, result.Merged = &idx, true
+		return result, nil
 	}
 
 	if task, err := s.repo.GetTask(id); err != nil {
-		return fmt.Errorf("не удалось получить задачу: %w", err)
+		return result, fmt.Errorf("не удалось получить задачу: %w", err)
 	} else if task.Status != repository.TaskFailed {
 		err = s.repo.UpdateTaskStatus(id, repository.TaskProcessing)
 		if err != nil {
-			return fmt.Errorf("не удалось обновить статус задачи: %w", err)
+			return result, fmt.Errorf("не удалось обновить статус задачи: %w", err)
 		}
 	}
 
-	go func(id int64, link string, s *TasksService, log *slog.Logger, cfg *config.Config) {
+	go func(id int64, idx int, link Link, s *TasksService, log *slog.Logger, cfg *config.Config) {
 		s.semaphore <- struct{}{}
-		s.DownloadFile(id, link, log, cfg)
-	}(id, link, s, log, cfg)
+		s.DownloadFile(id, idx, link, log, cfg)
+	}(id, idx, link, s, log, cfg)
 
-	return nil
+	return result, nil
 }
 
-func (s *TasksService) DownloadFile(id int64, link string, log *slog.Logger, cfg *config.Config) {
-	var err error
-	defer func(s *TasksService) { <-s.semaphore }(s)
-	loadedLink := ""
+// AppendLinks добавляет несколько ссылок и возвращает результат по каждой
+func (s *TasksService) AppendLinks(id int64, links []Link, log *slog.Logger, cfg *config.Config) []LinkResult {
+	results := make([]LinkResult, len(links))
+	for i, link := range links {
+		results[i], _ = s.AppendLink(id, link, log, cfg)
+	}
+	return results
+}
 
-	pass := false
-	for _, val := range strings.Split(cfg.AllowedExtensions, ",") {
-		if strings.HasSuffix(link, val) {
-			pass = true
-			break
-		}
+// CreateTaskWithLinks создаёт задачу сразу со списком ссылок. Такая задача закрывается
+// для новых ссылок: архив собирается, как только обработаны все принятые файлы
+func (s *TasksService) CreateTaskWithLinks(links []Link, archive repository.ArchiveOptions, log *slog.Logger, cfg *config.Config) (int64, []LinkResult, error) {
+	if len(links) == 0 {
+		return -1, nil, fmt.Errorf("список ссылок не может быть пустым")
+	}
+	id, err := s.CreateTask(archive, cfg)
+	if err != nil {
+		return -1, nil, err
 	}
 
-	if pass {
-		resp, err := http.Get(link)
-		if err != nil {
-			// log.Error("Ошибка при скачивании файла", slog.String("error", err.Error()), slog.Int64("task_id", id))
-			err = fmt.Errorf("ошибка при скачивании файла: %w", err)
-			s.handleErr(err, id, log)
-			return
-		}
-		defer resp.Body.Close()
+	results := s.AppendLinks(id, links, log, cfg)
+	if err := s.repo.SealTask(id); err != nil {
+		return id, results, fmt.Errorf("не удалось закрыть задачу: %w", err)
+	}
+	// файлы могли скачаться ещё до закрытия задачи
+	s.finalizeIfFinished(id, log, cfg)
 
-		if resp.StatusCode != 200 {
-			// log.Error("Ошибка при скачивании файла", slog.String("error", fmt.Sprintf("статус: %d", resp.StatusCode)), slog.Int64("task_id", id))
-			err = fmt.Errorf("файл не найден, статус: %d", resp.StatusCode)
-			s.handleErr(err, id, log)
-			return
+	accepted := 0
+	for _, result := range results {
+		if result.Accepted {
+			accepted++
 		}
+	}
+	if accepted == 0 {
+		s.handleTaskErr(fmt.Errorf("ни одна ссылка не принята"), id, log)
+	}
+	return id, results, nil
+}
 
-		fileName := fmt.Sprintf("%s/%d_%s", "./backend/static", id, getFileNameFromURL(link))
+// finishJob вызывается по окончании разбора страницы или ленты: найденных файлов
+// больше не будет, поэтому задача закрывается для новых ссылок
+func (s *TasksService) finishJob(id int64, log *slog.Logger, cfg *config.Config) {
+	if err := s.repo.UpdatePendingJobs(id, -1); err != nil {
+		log.Error("не удалось обновить задачу", slog.Int64("task_id", id), slog.String("error", err.Error()))
+	}
+	if err := s.repo.SealTask(id); err != nil {
+		log.Error("не удалось закрыть задачу", slog.Int64("task_id", id), slog.String("error", err.Error()))
+	}
+	s.finalizeIfFinished(id, log, cfg)
+}
 
-		if err := os.MkdirAll("./backend/static", os.ModePerm); err != nil {
-			// log.Error("Ошибка при создании директории", slog.String("error", err.Error()), slog.Int64("task_id", id))
-			err = fmt.Errorf("ошибка при создании директории: %w", err)
-			s.handleErr(err, id, log)
-			return
-		}
-		out, err := os.Create(fileName)
+// validateLink проверяет схемы основной ссылки и зеркал, параметры запроса и контрольную сумму
+func (s *TasksService) validateLink(link *Link) error {
+	if link.URL == "" {
+		return fmt.Errorf("ссылка не может быть пустой")
+	}
+	normalized, err := NormalizeURL(link.URL)
+	if err != nil {
+		return err
+	}
+	link.URL = normalized
+	mirrors := make([]string, 0, len(link.Mirrors))
+	for _, mirror := range link.Mirrors {
+		normalized, err := NormalizeURL(mirror)
 		if err != nil {
-			// log.Error("Ошибка при создании файла", slog.String("error", err.Error()), slog.Int64("task_id", id))
-			err = fmt.Errorf("ошибка при создании файла: %w", err)
-			s.handleErr(err, id, log)
-			return
+			return fmt.Errorf("зеркало: %w", err)
 		}
-		defer out.Close()
+		mirrors = append(mirrors, normalized)
+	}
+	if len(mirrors) > 0 {
+		link.Mirrors = mirrors
+	}
 
-		_, err = io.Copy(out, resp.Body)
+	for _, candidate := range append([]string{link.URL}, link.Mirrors...) {
+		u, err := url.Parse(candidate)
 		if err != nil {
-			// log.Error("Ошибка при сохранении файла", slog.String("error", err.Error()), slog.Int64("task_id", id))
-			err = fmt.Errorf("ошибка при сохранении файла: %w", err)
-			s.handleErr(err, id, log)
-			return
+			return fmt.Errorf("некорректная ссылка: %w", err)
+		}
+		if !s.fetchers.Supports(u.Scheme) {
+			return fmt.Errorf("неподдерживаемая схема ссылки: %s", u.Scheme)
 		}
-
-		loadedLink = fileName
-	} else {
-		// log.Error("Неверный формат файла", slog.String("link", link), slog.Int64("task_id", id))
-		err = fmt.Errorf("неверный формат файла, поддерживаются только .pdf и .jpeg")
-		s.handleErr(err, id, log)
-		return
 	}
-
-	err = s.repo.AppendLoadedFileLink(id, loadedLink)
+	if err := validateOptions(link.Options); err != nil {
+		return err
+	}
+	checksum, err := normalizeChecksum(link.Checksum)
 	if err != nil {
-		// log.Error("Ошибка при добавлении ссылки на загруженный файл", slog.String("error", err.Error()), slog.Int64("task_id", id))
-		err = fmt.Errorf("не удалось добавить ссылку на загруженный файл: %w", err)
-		s.handleErr(err, id, log)
-		return
+		return err
 	}
+	link.Checksum = checksum
+	link.Folder = cleanFolder(link.Folder)
+	link.Name = cleanFileName(link.Name)
+	return nil
 }
 
-func (s *TasksService) handleErr(err error, id int64, log *slog.Logger) {
+// appendFile добавляет файл в задачу. Если в задаче уже есть файл с той же ссылкой, то в режиме
+// merge ссылка записывается в его Aliases и возвращается его номер с duplicate=true
+func (s *TasksService) appendFile(id int64, file repository.File, submitted string, cfg *config.Config) (idx int, duplicate bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	task, err := s.repo.GetTask(id)
 	if err != nil {
-		info := fmt.Sprintf("ошибка при скачивании %d: %v\n", id, err)
-		if updateErr := s.repo.AppendError(id, info); updateErr != nil {
-			log.Error("не удалось записать ошибку задачи: ", strconv.FormatInt(id, 10), updateErr)
-		}
-		if updateErr := s.repo.UpdateTaskStatus(id, repository.TaskFailed); updateErr != nil {
-			log.Error("не удалось обновить статус задачи: ", strconv.FormatInt(id, 10), updateErr)
+		return -1, false, fmt.Errorf("не удалось получить задачу: %w", err)
+	}
+	if cfg.DedupMode != DedupOff {
+		for i, existing := range task.Files {
+			if existing.Key != file.Key {
+				continue
+			}
+			if cfg.DedupMode != DedupMerge {
+				return -1, false, fmt.Errorf("%w (файл %d)", ErrDuplicateLink, i)
+			}
+			err := s.repo.UpdateFile(id, i, func(f *repository.File) {
+				f.Aliases = append(f.Aliases, submitted)
+			})
+			if err != nil {
+				return -1, false, fmt.Errorf("не удалось обновить файл: %w", err)
+			}
+			return i, true, nil
 		}
 	}
-}
-
-func getFileNameFromURL(link string) string {
-	parts := strings.Split(link, "/")
-	if len(parts) == 0 {
-		return ""
+	if len(task.Files) >= cfg.MaxFilesPerTask {
+		return -1, false, fmt.Errorf("%w: %d", errFileLimit, cfg.MaxFilesPerTask)
+	}
+	// файлы, найденные разбором страницы или ленты, принимаются и после закрытия задачи
+	if task.Sealed && task.PendingJobs == 0 {
+		return -1, false, errTaskSealed
 	}
-	return parts[len(parts)-1]
-}
 
-func (s *TasksService) GetTask(id int64) (*repository.Task, error) {
-	task, err := s.repo.GetTask(id)
+	idx, err = s.repo.AppendFile(id, file)
 	if err != nil {
-		return nil, fmt.Errorf("не удалось получить задачу: %w", err)
+		return -1, false, fmt.Errorf("не удалось добавить ссылку: %w", err)
 	}
-	if len(task.LoadedFilesLinks)+len(task.Errors) >= 3 && len(task.LoadedFilesLinks) > 0 {
-		err = s.repo.UpdateTaskStatus(id, repository.TaskCompleted)
-		if err != nil {
-			return nil, fmt.Errorf("не удалось обновить статус задачи: %w", err)
+	if idx+1 >= cfg.MaxFilesPerTask {
+		if err := s.repo.SealTask(id); err != nil {
+			return -1, false, fmt.Errorf("не удалось закрыть задачу: %w", err)
 		}
 	}
-	return &task, nil
+	return idx, false, nil
 }
 
-func (s *TasksService) MakeArchive(task repository.Task) (repository.Task, error) {
-	archiveName := fmt.Sprintf("./backend/archives/%d_archive.zip", task.Id)
-	if err := os.MkdirAll("./backend/archives", os.ModePerm); err != nil {
-		err = fmt.Errorf("ошибка при создании директории: %w", err)
-		return task, err
-	}
-	archiveFile, err := os.Create(archiveName)
-	if err != nil {
-		return task, fmt.Errorf("ошибка при создании архива: %w", err)
-	}
-	defer archiveFile.Close()
-	zipWriter := zip.NewWriter(archiveFile)
-	defer zipWriter.Close()
+func (s *TasksService) DownloadFile(id int64, idx int, link Link, log *slog.Logger, cfg *config.Config) {
+	defer func(s *TasksService) { <-s.semaphore }(s)
+	defer s.finalizeIfFinished(id, log, cfg)
+
+	// Сначала основная ссылка, затем зеркала в порядке, заданном клиентом
+	candidates := append([]string{link.URL}, link.Mirrors...)
+	var errs []string
+	var lastErr error
+	for i, candidate := range candidates {
+		result, err := s.downloadWithRetries(id, idx, candidate, link, log, cfg)
+		if err != nil {
+			log.Warn("Не удалось скачать файл", slog.Int64("task_id", id), slog.Int("mirror", i), slog.String("error", err.Error()))
+			errs = append(errs, fmt.Sprintf("%s: %v", RedactLink(candidate), err))
+			lastErr = err
+			continue
+		}
 
-	for _, filePath := range task.LoadedFilesLinks {
-		if err := addFileToZip(zipWriter, filePath); err != nil {
-			return task, fmt.Errorf("ошибка при добавлении файла %s в архив: %w", filePath, err)
+		err = s.repo.UpdateFile(id, idx, func(file *repository.File) {
+			file.Name = result.Name
+			file.Path = result.Path
+			file.Status = repository.FileLoaded
+			file.Source = RedactLink(candidate)
+			file.FinalURL = result.FinalURL
+			file.Redirects = result.Redirects
+			file.Checksum = result.Checksum
+			file.ActualChecksum = result.ActualChecksum
+			file.Size = result.Size
+			file.LastModified = result.LastModified
+			if result.ContentType != "" {
+				file.ContentType = result.ContentType
+			}
+		})
+		if err != nil {
+			// log.Error("Ошибка при добавлении ссылки на загруженный файл", slog.String("error", err.Error()), slog.Int64("task_id", id))
+			err = fmt.Errorf("не удалось добавить ссылку на загруженный файл: %w", err)
+			s.handleErr(err, id, idx, log)
 		}
+		return
 	}
 
-	if err := s.repo.UpdateArchiveName(task.Id, archiveName); err != nil {
-		return task, fmt.Errorf("ошибка при обновлении имени архива: %w", err)
+	var mismatch *checksumError
+	if errors.As(lastErr, &mismatch) {
+		s.repo.UpdateFile(id, idx, func(file *repository.File) {
+			file.Checksum = mismatch.Sum
+			file.ActualChecksum = mismatch.Actual
+		})
 	}
-	task.ArchivePath = archiveName
 
-	return task, nil
+	if len(candidates) == 1 {
+		s.handleErr(lastErr, id, idx, log)
+		return
+	}
+	s.handleErr(fmt.Errorf("не удалось скачать файл ни по одной из ссылок: %s", strings.Join(errs, "; ")), id, idx, log)
 }
 
-func addFileToZip(zipWriter *zip.Writer, filePath string) error {
-	file, err := os.Open(filePath)
+func (s *TasksService) handleErr(err error, id int64, idx int, log *slog.Logger) {
 	if err != nil {
-		return fmt.Errorf("ошибка при открытии файла: %w", err)
+		if updateErr := s.repo.UpdateFile(id, idx, func(file *repository.File) {
+			file.Status = repository.FileFailed
+			file.Error = err.Error()
+		}); updateErr != nil {
+			log.Error("не удалось обновить файл задачи: ", strconv.FormatInt(id, 10), updateErr)
+		}
+		s.handleTaskErr(err, id, log)
 	}
-	defer file.Close()
+}
 
-	w, err := zipWriter.Create(getFileNameFromURL(filePath))
-	if err != nil {
-		return fmt.Errorf("ошибка при создании файла в архиве: %w", err)
+func (s *TasksService) handleTaskErr(err error, id int64, log *slog.Logger) {
+	info := fmt.Sprintf("ошибка при скачивании %d: %v\n", id, err)
+	if updateErr := s.repo.AppendError(id, info); updateErr != nil {
+		log.Error("не удалось записать ошибку задачи: ", strconv.FormatInt(id, 10), updateErr)
 	}
-
-	_, err = io.Copy(w, file)
-	if err != nil {
-		return fmt.Errorf("ошибка при копировании файла в архив: %w", err)
+	if updateErr := s.repo.UpdateTaskStatus(id, repository.TaskFailed); updateErr != nil {
+		log.Error("не удалось обновить статус задачи: ", strconv.FormatInt(id, 10), updateErr)
 	}
-
-	return nil
 }
 
-func (s *TasksService) GetArchivePath(id int64) (string, error) {
+// GetTask только читает задачу: статус меняют загрузки и сборка архива в фоне
+func (s *TasksService) GetTask(id int64) (*repository.Task, error) {
 	task, err := s.repo.GetTask(id)
 	if err != nil {
-		return "", fmt.Errorf("не удалось получить задачу: %w", err)
-	}
-	if task.ArchivePath == "" {
-		return "", fmt.Errorf("архив для задачи %d не найден", id)
+		return nil, fmt.Errorf("не удалось получить задачу: %w", err)
 	}
-	return task.ArchivePath, nil
+	return &task, nil
 }
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"backend/internal/config"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTemplateSize - верхняя граница числа ссылок в одном шаблоне, чтобы не строить
+// огромные списки до проверки лимита файлов задачи
+const maxTemplateSize = 10000
+
+var (
+	numericRange = regexp.MustCompile(`^(\d+)-(\d+)(?::(\d+))?$`)
+	dateRange    = regexp.MustCompile(`^date:([0-9-]+)\.\.([0-9-]+)(?:\|(.+))?$`)
+)
+
+// TemplatePreview - результат пробного раскрытия шаблона ссылки
+type TemplatePreview struct {
+	Links     []string `json:"links"`
+	Total     int      `json:"total"`
+	Remaining int      `json:"remaining"`
+}
+
+// ExpandTemplate раскрывает шаблон ссылки:
+//   - [001-120] - числовой диапазон, ведущие нули задают ширину, [0-100:10] - с шагом;
+//   - {a,b,c} - перечисление;
+//   - {date:2024-01-01..2024-01-31} - даты по дням, {date:2024-01..2024-12} - по месяцам,
+//     после | можно указать формат в нотации Go, например {date:2024-01-01..2024-01-31|20060102}.
+//
+// Скобки, не подходящие ни под один вид (например IPv6-адрес хоста), остаются как есть
+func ExpandTemplate(template string) ([]string, error) {
+	parts, err := parseTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+
+	total := 1
+	for _, values := range parts {
+		total *= len(values)
+		if total > maxTemplateSize {
+			return nil, fmt.Errorf("шаблон раскрывается больше чем в %d ссылок", maxTemplateSize)
+		}
+	}
+
+	links := make([]string, 0, total)
+	counters := make([]int, len(parts))
+	for {
+		var b strings.Builder
+		for i, values := range parts {
+			b.WriteString(values[counters[i]])
+		}
+		links = append(links, b.String())
+
+		i := len(parts) - 1
+		for ; i >= 0; i-- {
+			counters[i]++
+			if counters[i] < len(parts[i]) {
+				break
+			}
+			counters[i] = 0
+		}
+		if i < 0 {
+			return links, nil
+		}
+	}
+}
+
+// parseTemplate делит шаблон на части: литерал - часть с одним значением
+func parseTemplate(template string) ([][]string, error) {
+	var parts [][]string
+	literal := 0
+	for i := 0; i < len(template); i++ {
+		open := template[i]
+		if open != '[' && open != '{' {
+			continue
+		}
+		closing := byte(']')
+		if open == '{' {
+			closing = '}'
+		}
+		end := strings.IndexByte(template[i+1:], closing)
+		if end < 0 {
+			continue
+		}
+		body := template[i+1 : i+1+end]
+
+		values, ok, err := expandGroup(open, body)
+		if err != nil {
+			return nil, fmt.Errorf("шаблон %c%s%c: %w", open, body, closing, err)
+		}
+		if !ok {
+			continue
+		}
+		parts = append(parts, []string{template[literal:i]}, values)
+		i += end + 1
+		literal = i + 1
+	}
+	return append(parts, []string{template[literal:]}), nil
+}
+
+func expandGroup(open byte, body string) ([]string, bool, error) {
+	if open == '[' {
+		m := numericRange.FindStringSubmatch(body)
+		if m == nil {
+			return nil, false, nil
+		}
+		values, err := expandNumbers(m[1], m[2], m[3])
+		return values, true, err
+	}
+
+	if m := dateRange.FindStringSubmatch(body); m != nil {
+		values, err := expandDates(m[1], m[2], m[3])
+		return values, true, err
+	}
+	if strings.Contains(body, ",") {
+		return strings.Split(body, ","), true, nil
+	}
+	return nil, false, nil
+}
+
+func expandNumbers(from, to, step string) ([]string, error) {
+	start, err := strconv.Atoi(from)
+	if err != nil {
+		return nil, fmt.Errorf("некорректное начало диапазона")
+	}
+	end, err := strconv.Atoi(to)
+	if err != nil {
+		return nil, fmt.Errorf("некорректный конец диапазона")
+	}
+	inc := 1
+	if step != "" {
+		if inc, err = strconv.Atoi(step); err != nil || inc <= 0 {
+			return nil, fmt.Errorf("шаг диапазона должен быть положительным числом")
+		}
+	}
+	if end < start {
+		return nil, fmt.Errorf("конец диапазона меньше начала")
+	}
+	if (end-start)/inc+1 > maxTemplateSize {
+		return nil, fmt.Errorf("диапазон больше %d значений", maxTemplateSize)
+	}
+
+	width := 0
+	if len(from) > 1 && from[0] == '0' {
+		width = len(from)
+	}
+	values := make([]string, 0, (end-start)/inc+1)
+	for n := start; n <= end; n += inc {
+		values = append(values, fmt.Sprintf("%0*d", width, n))
+	}
+	return values, nil
+}
+
+func expandDates(from, to, layout string) ([]string, error) {
+	input, monthly := "2006-01-02", false
+	if len(from) == len("2006-01") {
+		input, monthly = "2006-01", true
+	}
+	start, err := time.Parse(input, from)
+	if err != nil {
+		return nil, fmt.Errorf("дата должна быть в формате 2006-01-02 или 2006-01")
+	}
+	end, err := time.Parse(input, to)
+	if err != nil {
+		return nil, fmt.Errorf("дата должна быть в формате 2006-01-02 или 2006-01")
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("конец периода раньше начала")
+	}
+	if layout == "" {
+		layout = input
+	}
+
+	var values []string
+	for t := start; !t.After(end); {
+		if len(values) >= maxTemplateSize {
+			return nil, fmt.Errorf("период больше %d значений", maxTemplateSize)
+		}
+		values = append(values, t.Format(layout))
+		if monthly {
+			t = t.AddDate(0, 1, 0)
+		} else {
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+	return values, nil
+}
+
+// PreviewTemplate раскрывает шаблон без добавления ссылок и показывает, сколько мест осталось в задаче
+func (s *TasksService) PreviewTemplate(id int64, template string, cfg *config.Config) (*TemplatePreview, error) {
+	links, err := ExpandTemplate(template)
+	if err != nil {
+		return nil, err
+	}
+	remaining, err := s.remainingFiles(id, cfg)
+	if err != nil {
+		return nil, err
+	}
+	for i, link := range links {
+		links[i] = RedactLink(link)
+	}
+	return &TemplatePreview{Links: links, Total: len(links), Remaining: remaining}, nil
+}
+
+// appendTemplate раскрывает шаблон и добавляет каждую ссылку отдельным файлом. Сначала
+// проверяются все ссылки, повторы и свободные места в задаче: если хоть одна проверка
+// не пройдена, не добавляется ни одна ссылка
+func (s *TasksService) appendTemplate(id int64, link Link, log *slog.Logger, cfg *config.Config) error {
+	if link.Name != "" || len(link.Mirrors) > 0 {
+		return fmt.Errorf("для шаблона ссылки нельзя задать имя файла или зеркала")
+	}
+	links, err := ExpandTemplate(link.URL)
+	if err != nil {
+		return err
+	}
+
+	pending := make([]pendingLink, len(links))
+	for i, expanded := range links {
+		single := link
+		single.URL = expanded
+		single.Expand = false
+		if pending[i], err = s.prepareLink(single); err != nil {
+			return fmt.Errorf("ссылка %s: %w", RedactLink(expanded), err)
+		}
+	}
+	if err := s.checkTemplateFiles(id, pending, cfg); err != nil {
+		return err
+	}
+	for i := range pending {
+		if err := s.preflightLink(&pending[i], cfg); err != nil {
+			return fmt.Errorf("ссылка %s: %w", pending[i].submitted, err)
+		}
+	}
+
+	for _, p := range pending {
+		if _, err := s.commitLink(id, p, log, cfg); err != nil {
+			return fmt.Errorf("ссылка %s: %w", p.submitted, err)
+		}
+	}
+	log.Info("Шаблон ссылки раскрыт", slog.Int64("task_id", id), slog.Int("files", len(links)))
+	return nil
+}
+
+// checkTemplateFiles проверяет, что ссылки шаблона не повторяют друг друга и файлы задачи
+// (при DEDUP_MODE=reject) и что новых файлов не больше, чем свободных мест
+func (s *TasksService) checkTemplateFiles(id int64, pending []pendingLink, cfg *config.Config) error {
+	remaining, err := s.remainingFiles(id, cfg)
+	if err != nil {
+		return err
+	}
+	task, err := s.repo.GetTask(id)
+	if err != nil {
+		return fmt.Errorf("не удалось получить задачу: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	if cfg.DedupMode != DedupOff {
+		for _, file := range task.Files {
+			seen[file.Key] = true
+		}
+	}
+	added := 0
+	for _, p := range pending {
+		if p.file.Key == "" || cfg.DedupMode == DedupOff {
+			added++
+			continue
+		}
+		if seen[p.file.Key] {
+			if cfg.DedupMode != DedupMerge {
+				return fmt.Errorf("%w: %s", ErrDuplicateLink, p.submitted)
+			}
+			continue
+		}
+		seen[p.file.Key] = true
+		added++
+	}
+	if added > remaining {
+		return fmt.Errorf("%w: шаблон раскрывается в %d ссылок, свободно %d", errFileLimit, added, remaining)
+	}
+	return nil
+}
+
+func (s *TasksService) remainingFiles(id int64, cfg *config.Config) (int, error) {
+	task, err := s.repo.GetTask(id)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось получить задачу: %w", err)
+	}
+	if task.Sealed && task.PendingJobs == 0 {
+		return 0, nil
+	}
+	return max(cfg.MaxFilesPerTask-len(task.Files), 0), nil
+}
@@ -0,0 +1,136 @@
+package service
+
+import (
+	"backend/internal/config"
+	"backend/internal/fetcher"
+	"backend/internal/repository"
+	"errors"
+	"io"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandTemplate(t *testing.T) {
+	tests := []struct {
+		template string
+		want     []string
+	}{
+		{"https://h/p[1-3].jpg", []string{"https://h/p1.jpg", "https://h/p2.jpg", "https://h/p3.jpg"}},
+		{"https://h/p[008-010].jpg", []string{"https://h/p008.jpg", "https://h/p009.jpg", "https://h/p010.jpg"}},
+		{"https://h/p[0-20:10].jpg", []string{"https://h/p0.jpg", "https://h/p10.jpg", "https://h/p20.jpg"}},
+		{"https://h/{a,b}/[1-2].pdf", []string{"https://h/a/1.pdf", "https://h/a/2.pdf", "https://h/b/1.pdf", "https://h/b/2.pdf"}},
+		{"https://h/{date:2024-01-30..2024-02-01|20060102}.pdf", []string{"https://h/20240130.pdf", "https://h/20240131.pdf", "https://h/20240201.pdf"}},
+		{"https://h/{date:2023-11..2024-01}.pdf", []string{"https://h/2023-11.pdf", "https://h/2023-12.pdf", "https://h/2024-01.pdf"}},
+		{"https://[::1]/a.pdf", []string{"https://[::1]/a.pdf"}},
+		{"https://h/{single}.pdf", []string{"https://h/{single}.pdf"}},
+		{"https://h/[abc.pdf", []string{"https://h/[abc.pdf"}},
+	}
+	for _, tt := range tests {
+		got, err := ExpandTemplate(tt.template)
+		if err != nil {
+			t.Errorf("%s: %v", tt.template, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: получено %v, ожидалось %v", tt.template, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTemplateLimits(t *testing.T) {
+	tests := []struct {
+		template string
+		err      string
+	}{
+		{"https://h/[1-10001].jpg", "диапазон больше"},
+		{"https://h/[0-1000000000:1].jpg", "диапазон больше"},
+		{"https://h/[1-100]/[1-101].jpg", "раскрывается больше чем в 10000"},
+		{"https://h/[1-10]/[1-10]/[1-10]/[1-10]/[1-2].jpg", "раскрывается больше чем в 10000"},
+		{"https://h/{date:1990-01-01..2030-01-01}.pdf", "период больше"},
+		{"https://h/[5-1].jpg", "конец диапазона меньше начала"},
+		{"https://h/[1-5:0].jpg", "шаг диапазона"},
+		{"https://h/[1-99999999999999999999].jpg", "некорректный конец диапазона"},
+		{"https://h/{date:2024-02-01..2024-01-01}.pdf", "конец периода раньше начала"},
+		{"https://h/{date:2024-13-01..2024-12-01}.pdf", "дата должна быть"},
+	}
+	for _, tt := range tests {
+		links, err := ExpandTemplate(tt.template)
+		if err == nil {
+			t.Errorf("%s: ожидалась ошибка, получено %d ссылок", tt.template, len(links))
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: ошибка %q не содержит %q", tt.template, err, tt.err)
+		}
+	}
+
+	// ровно на границе шаблон ещё раскрывается
+	links, err := ExpandTemplate("https://h/[1-100]/[1-100].jpg")
+	if err != nil || len(links) != maxTemplateSize {
+		t.Errorf("шаблон на %d ссылок: %d, %v", maxTemplateSize, len(links), err)
+	}
+}
+
+func TestAppendTemplateAllOrNothing(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	newService := func(t *testing.T, dedup string, existing ...string) (*TasksService, int64, *config.Config) {
+		t.Helper()
+		cfg := &config.Config{AllowedExtensions: ".pdf", DedupMode: dedup, MaxFilesPerTask: 3}
+		fetchers, err := fetcher.NewRegistry(cfg, log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		repo := repository.NewTasksRepository()
+		id, err := repo.CreateTask(repository.ArchiveOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, link := range existing {
+			repo.AppendFile(id, repository.File{Link: link, Key: dedupKey(link), Status: repository.FileLoaded})
+		}
+		return NewTasksService(repo, fetchers, &Rewriter{}, nil), id, cfg
+	}
+
+	tests := []struct {
+		name     string
+		dedup    string
+		existing []string
+		template string
+		err      error
+	}{
+		{"повтор внутри шаблона", DedupReject, nil, "https://h/{a,b,a}.pdf", ErrDuplicateLink},
+		{"повтор после нормализации", DedupReject, nil, "{https://h,HTTPS://H:443}/a.pdf", ErrDuplicateLink},
+		{"повтор файла задачи", DedupReject, []string{"https://h/b.pdf"}, "https://h/{a,b}.pdf", ErrDuplicateLink},
+		{"не хватает мест", DedupReject, nil, "https://h/[1-4].pdf", errFileLimit},
+		{"не хватает мест с файлами задачи", DedupMerge, []string{"https://h/x.pdf", "https://h/y.pdf"}, "https://h/{a,b}.pdf", errFileLimit},
+		{"повторы без дедупликации занимают места", DedupOff, []string{"https://h/a.pdf"}, "https://h/{a,a,a}.pdf", errFileLimit},
+		{"одна ссылка не проходит проверку", DedupReject, nil, "{https,gopher}://h/a.pdf", nil},
+	}
+	for _, tt := range tests {
+		s, id, cfg := newService(t, tt.dedup, tt.existing...)
+		err := s.appendTemplate(id, Link{URL: tt.template, Expand: true}, log, cfg)
+		if err == nil || tt.err != nil && !errors.Is(err, tt.err) {
+			t.Errorf("%s: ошибка = %v, ожидалась %v", tt.name, err, tt.err)
+		}
+		task, _ := s.repo.GetTask(id)
+		if len(task.Files) != len(tt.existing) {
+			t.Errorf("%s: в задаче %d файлов, ожидалось %d: шаблон добавлен частично", tt.name, len(task.Files), len(tt.existing))
+		}
+	}
+
+	// в режиме merge повторы объединяются и не занимают мест
+	s, id, cfg := newService(t, DedupMerge, "https://h/a.pdf")
+	pending := make([]pendingLink, 0, 4)
+	for _, link := range []string{"https://h/a.pdf", "https://h/b.pdf", "https://h/c.pdf", "https://h/b.pdf"} {
+		p, err := s.prepareLink(Link{URL: link})
+		if err != nil {
+			t.Fatal(err)
+		}
+		pending = append(pending, p)
+	}
+	if err := s.checkTemplateFiles(id, pending, cfg); err != nil {
+		t.Errorf("merge: %v", err)
+	}
+}
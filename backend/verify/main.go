@@ -0,0 +1,54 @@
+// verify проверяет подпись архива, скачанного из архиватора:
+//
+//	go run ./verify -key signing-key.pem -sig archive.zip.sig archive.zip
+//
+// Открытый ключ отдаётся по /.well-known/archiver-signing-key, подпись - по /api/archives/{id}/signature
+package main
+
+import (
+	"backend/internal/service"
+	"flag"
+	"fmt"
+	"os"
+)
+
+func main() {
+	keyFile := flag.String("key", "", "открытый ключ подписи в PEM")
+	sigFile := flag.String("sig", "", "файл подписи, по умолчанию <архив>.sig")
+	flag.Parse()
+	if *keyFile == "" || flag.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "использование: verify -key signing-key.pem [-sig archive.zip.sig] archive.zip")
+		os.Exit(2)
+	}
+	archivePath := flag.Arg(0)
+	if *sigFile == "" {
+		*sigFile = archivePath + ".sig"
+	}
+
+	if err := verify(*keyFile, *sigFile, archivePath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println("Подпись верна")
+}
+
+func verify(keyFile, sigFile, archivePath string) error {
+	keyData, err := os.ReadFile(keyFile)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать ключ: %w", err)
+	}
+	publicKey, err := service.ParsePublicKey(keyData)
+	if err != nil {
+		return err
+	}
+	signature, err := os.ReadFile(sigFile)
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать подпись: %w", err)
+	}
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть архив: %w", err)
+	}
+	defer archive.Close()
+	return service.VerifyArchive(publicKey, archive, signature)
+}